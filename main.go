@@ -1,62 +1,168 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/Duke1616/vuefinder-go/pkg/finder"
 	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/Duke1616/vuefinder-go/pkg/policy"
+	"github.com/Duke1616/vuefinder-go/pkg/session"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
 	"github.com/Duke1616/vuefinder-go/pkg/web"
 	"github.com/gin-gonic/gin"
-	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"log"
+	"os"
+	"path/filepath"
+	"time"
 )
 
 func main() {
 	// 定义命令行参数
 	host := flag.String("host", "127.0.0.1:22", "SSH server host and port")
 	user := flag.String("user", "", "SSH username")
-	password := flag.String("password", "", "SSH password")
+	password := flag.String("password", "", "SSH password, also used to answer keyboard-interactive prompts")
+	keyFile := flag.String("key", "", "SSH private key file")
+	passphrase := flag.String("passphrase", "", "Passphrase of the SSH private key")
+	certFile := flag.String("cert", "", "OpenSSH certificate file for the private key")
+	useAgent := flag.Bool("agent", false, "Authenticate with the ssh-agent from SSH_AUTH_SOCK")
+	keyboardInteractive := flag.Bool("keyboard-interactive", false, "Enable keyboard-interactive authentication")
+	knownHosts := flag.String("known-hosts", defaultKnownHosts(), "known_hosts file used to verify the SSH host key")
+	fingerprint := flag.String("host-key-fingerprint", "", "Pinned SHA256 fingerprint of the SSH host key")
+	tofu := flag.Bool("tofu", false, "Trust unknown host keys on first use and record them in known_hosts")
+	insecure := flag.Bool("insecure-ignore-host-key", false, "Skip SSH host key verification")
+	demo := flag.Bool("demo", false, "Serve an in-memory file system as finder id 20 instead of SSH")
+	local := flag.String("local", "", "Local directory to serve as finder id 10")
+	root := flag.String("root", "", "Confine the SSH session and the default credential to this remote directory")
+	readOnly := flag.Bool("read-only", false, "Only allow browsing, searching, downloading and previewing in every session, including sessions created through the session API")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3 compatible endpoint to serve as finder id 30")
+	s3AccessKey := flag.String("s3-access-key", "", "S3 access key")
+	s3SecretKey := flag.String("s3-secret-key", "", "S3 secret key")
+	s3Secure := flag.Bool("s3-secure", false, "Use HTTPS for the S3 endpoint")
+	keepalive := flag.Duration("keepalive", 30*time.Second, "Interval of SSH keepalive probes, 0 disables them")
+	credentialsFile := flag.String("credentials", "", "JSON file of named SSH credentials referenced by the session API")
+	authFile := flag.String("auth", "", "JSON file configuring JWT, API token and htpasswd authentication of the HTTP API")
+	policyFile := flag.String("policy", "", "JSON file of path based authorization rules applied to the file browser API and the admins of the session API")
 
 	// 解析命令行参数
 	flag.Parse()
 
-	// 检查必填参数
-	if *user == "" || *password == "" {
-		log.Fatal("Username and password are required")
+	auth := sshx.Auth{
+		Password:            *password,
+		KeyFile:             *keyFile,
+		Passphrase:          *passphrase,
+		CertFile:            *certFile,
+		Agent:               *useAgent,
+		KeyboardInteractive: *keyboardInteractive,
 	}
 
-	// 连接到 SSH 服务器
-	client, err := ConnectSSH(*host, *user, *password)
+	creds := make(map[string]session.Credential)
+	if *credentialsFile != "" {
+		var err error
+		if creds, err = session.LoadCredentials(*credentialsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// 命令行中的认证方式作为 default 凭证，供会话接口引用，只允许连接 -host，并且限制在 -root 下
+	cli := session.Credential{Auth: auth, Hosts: []string{*host}, Root: *root, ReadOnly: *readOnly}
+	if _, ok := creds["default"]; !ok && auth != (sshx.Auth{}) {
+		creds["default"] = cli
+	}
+	// -read-only 同样作用于配置文件中的凭证，客户端无法解除
+	if *readOnly {
+		for name, credential := range creds {
+			credential.ReadOnly = true
+			creds[name] = credential
+		}
+	}
 
-	sftpClient, err := sftp.NewClient(client)
-	f := finder.NewSftpFinder(sftpClient)
-	handler := web.NewHandler()
-	handler.SetFinder(20, f)
+	// 命令行中 0 表示关闭心跳，Config 中 0 表示使用默认值
+	keepaliveInterval := *keepalive
+	if keepaliveInterval == 0 {
+		keepaliveInterval = -1
+	}
+	sessions := session.NewManager(creds, sshx.Config{
+		HostKey: sshx.HostKeyPolicy{
+			KnownHosts:      *knownHosts,
+			Fingerprint:     *fingerprint,
+			TrustOnFirstUse: *tofu,
+			Insecure:        *insecure,
+		},
+		KeepaliveInterval: keepaliveInterval,
+	})
+	// register -read-only 作用于启动时注册的所有会话
+	register := func(id int64, kind string, f finder.Finder) {
+		if *readOnly {
+			f = finder.NewReadOnlyFinder(f)
+		}
+		sessions.Register(id, kind, f)
+	}
+	if *demo {
+		// 演示模式使用内存文件系统，无需 SSH 服务器
+		f := finder.NewMemoryFinder()
+		if err := f.NewFolder(context.Background(), "/", "home"); err != nil {
+			log.Fatal(err)
+		}
+		register(20, session.KindMemory, f)
+	} else if *user != "" {
+		// 连接到 SSH 服务器
+		if _, err := sessions.Connect(20, session.OpenReq{Host: *host, User: *user}, cli); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Println("No SSH user given, create sessions through /api/session/create")
+	}
+	if *local != "" {
+		register(10, session.KindLocal, finder.NewLocalFinder(*local))
+	}
+	if *s3Endpoint != "" {
+		s3Client, err := minio.New(*s3Endpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(*s3AccessKey, *s3SecretKey, ""),
+			Secure: *s3Secure,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		register(30, session.KindS3, finder.NewS3Finder(s3Client))
+	}
 	mlds := ginx.NewMiddleware()
-	engine := gin.Default()
+	// 不使用 gin.Default，默认的访问日志会记录查询参数中的 access_token
+	engine := gin.New()
 	engine.Use(mlds...)
+	if *authFile != "" {
+		cfg, err := ginx.LoadAuthConfig(*authFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authMiddleware, err := ginx.NewAuthMiddleware(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		engine.Use(authMiddleware)
+	} else {
+		log.Println("No -auth config given, the HTTP API accepts unauthenticated requests")
+	}
+	handler, sessionHandler := web.NewHandler(sessions), web.NewSessionHandler(sessions)
+	if *policyFile != "" {
+		p, err := policy.Load(*policyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler, sessionHandler = web.NewHandlerWithPolicy(sessions, p), web.NewSessionHandlerWithPolicy(sessions, p)
+	}
 	handler.RegisterRoutes(engine)
-	err = engine.Run(":8350")
-	if err != nil {
+	sessionHandler.RegisterRoutes(engine)
+	if err := engine.Run(":8350"); err != nil {
 		panic(err)
 	}
 }
 
-func ConnectSSH(host, user, password string) (*ssh.Client, error) {
-	// 创建 SSH 客户端配置
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 不推荐在生产环境中使用
-	}
-
-	// 连接到 SSH 服务器
-	client, err := ssh.Dial("tcp", host, config)
+func defaultKnownHosts() string {
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, err
+		return ""
 	}
 
-	return client, nil
+	return filepath.Join(home, ".ssh", "known_hosts")
 }
@@ -0,0 +1,631 @@
+package finder
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy 目标文件已存在时的处理方式
+type ConflictPolicy string
+
+const (
+	// ConflictError 存在冲突时返回错误，不写入任何文件，默认策略
+	ConflictError ConflictPolicy = "error"
+	// ConflictSkip 跳过已存在的文件
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite 覆盖已存在的文件
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictRename 在名称后追加序号，只有复制支持
+	ConflictRename ConflictPolicy = "rename"
+)
+
+// ArchiveFormat 压缩包格式
+type ArchiveFormat string
+
+const (
+	// FormatZip 默认格式，打包时跟随软链接
+	FormatZip ArchiveFormat = "zip"
+	// FormatTar tar 格式保留权限、属主以及软链接
+	FormatTar    ArchiveFormat = "tar"
+	FormatTarGz  ArchiveFormat = "tar.gz"
+	FormatTarZst ArchiveFormat = "tar.zst"
+)
+
+var (
+	// ErrUnsafePath 压缩包中的路径超出了解压目录，例如 ../../etc/passwd
+	ErrUnsafePath = errors.New("path escapes the target directory")
+	// ErrUnknownFormat 不支持的压缩包格式
+	ErrUnknownFormat = errors.New("unknown archive format")
+)
+
+// archiveExtensions 文件后缀对应的格式，较长的后缀需要排在前面
+var archiveExtensions = []struct {
+	ext    string
+	format ArchiveFormat
+}{
+	{ext: ".tar.gz", format: FormatTarGz},
+	{ext: ".tgz", format: FormatTarGz},
+	{ext: ".tar.zst", format: FormatTarZst},
+	{ext: ".tzst", format: FormatTarZst},
+	{ext: ".tar", format: FormatTar},
+	{ext: ".zip", format: FormatZip},
+}
+
+// DetectFormat 根据文件后缀识别压缩包格式
+func DetectFormat(name string) (ArchiveFormat, bool) {
+	ext, format := archiveExt(name)
+	return format, ext != ""
+}
+
+// TrimArchiveExt 去掉压缩包后缀，例如 logs.tar.gz 返回 logs
+func TrimArchiveExt(name string) string {
+	ext, _ := archiveExt(name)
+	if ext == "" {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	return name[:len(name)-len(ext)]
+}
+
+func archiveExt(name string) (string, ArchiveFormat) {
+	lower := strings.ToLower(name)
+	for _, e := range archiveExtensions {
+		if strings.HasSuffix(lower, e.ext) {
+			return e.ext, e.format
+		}
+	}
+
+	return "", ""
+}
+
+// ArchiveName 返回 Archive 实际写入的压缩包路径，例如 logs 按照 tar.gz 格式打包时为 logs.tar.gz
+func ArchiveName(target string, format ArchiveFormat) (string, error) {
+	name, _, err := archiveName(target, format)
+	return name, err
+}
+
+// archiveName 计算压缩包名称，未指定格式时根据后缀识别，默认为 zip，后缀与格式不一致时自行添加
+func archiveName(target string, format ArchiveFormat) (string, ArchiveFormat, error) {
+	detected, ok := DetectFormat(target)
+	if format == "" {
+		if ok {
+			return target, detected, nil
+		}
+		format = FormatZip
+	}
+
+	switch format {
+	case FormatZip, FormatTar, FormatTarGz, FormatTarZst:
+	default:
+		return "", "", pathError("archive", string(format), ErrUnknownFormat)
+	}
+
+	if detected != format {
+		target += "." + string(format)
+	}
+
+	return target, format, nil
+}
+
+// archiveSource 打包以及复制时读取的文件系统，由各个 Finder 实现
+type archiveSource interface {
+	Lstat(path string) (fs.FileInfo, error)
+	Stat(path string) (fs.FileInfo, error)
+	ReadLink(path string) (string, error)
+	ReadDir(path string) ([]fs.FileInfo, error)
+	Open(path string) (io.ReadCloser, error)
+}
+
+// archiveWriter 按照格式写入压缩包条目
+type archiveWriter interface {
+	// Add 写入条目头，普通文件随后通过返回的 Writer 写入内容，link 为软链接指向的路径
+	Add(name string, info fs.FileInfo, link string) (io.Writer, error)
+	// KeepLinks 是否保存软链接本身，不支持时打包软链接指向的内容
+	KeepLinks() bool
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) (archiveWriter, error) {
+	if format == FormatZip {
+		return zipWriter{Writer: zip.NewWriter(w)}, nil
+	}
+
+	return newTarWriter(w, format)
+}
+
+// pack 将 items 打包写入 w，压缩包内的路径为去掉 base 前缀后的相对路径
+func pack(ctx context.Context, src archiveSource, items []Item, base string, w io.Writer, format ArchiveFormat) error {
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if blockOperation("archive", base, item.Path) {
+			continue
+		}
+
+		info, err := src.Lstat(item.Path)
+		if err != nil {
+			_ = aw.Close()
+			return err
+		}
+
+		if err = walkArchive(ctx, src, aw, item.Path, base, info); err != nil {
+			_ = aw.Close()
+			return err
+		}
+	}
+
+	return aw.Close()
+}
+
+func walkArchive(ctx context.Context, src archiveSource, aw archiveWriter, path, base string, info fs.FileInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 去掉公共前缀
+	name := strings.TrimPrefix(strings.TrimPrefix(path, base), "/")
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if aw.KeepLinks() {
+			link, err := src.ReadLink(path)
+			if err != nil {
+				return err
+			}
+
+			_, err = aw.Add(name, info, link)
+			return err
+		}
+
+		var err error
+		if info, err = src.Stat(path); err != nil {
+			return err
+		}
+	}
+
+	writer, err := aw.Add(name, info, "")
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := src.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(writer, file)
+		return err
+	}
+
+	entries, err := src.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err = walkArchive(ctx, src, aw, filepath.Join(path, entry.Name()), base, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (w zipWriter) Add(name string, info fs.FileInfo, link string) (io.Writer, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+		header.Method = zip.Store
+	} else {
+		header.Method = zip.Deflate
+	}
+
+	return w.CreateHeader(header)
+}
+
+func (w zipWriter) KeepLinks() bool {
+	return false
+}
+
+// extractTarget 解压时写入的文件系统，由各个 Finder 实现
+type extractTarget interface {
+	Lstat(path string) (fs.FileInfo, error)
+	MkdirAll(path string) error
+	Create(path string) (io.WriteCloser, error)
+	RemoveAll(path string) error
+}
+
+// abortWriter Create 返回的文件写入失败时丢弃已写入的内容，例如中断对象存储的上传，避免留下不完整的文件
+type abortWriter interface {
+	CloseWithError(err error) error
+}
+
+// symlinkTarget 支持软链接的文件系统，未实现时跳过压缩包中的软链接
+type symlinkTarget interface {
+	Symlink(link, path string) error
+}
+
+// chmodTarget 支持权限位的文件系统，未实现时不恢复权限
+type chmodTarget interface {
+	Chmod(path string, mode fs.FileMode) error
+}
+
+// unarchive 根据压缩包后缀选择解压方式
+func unarchive(ctx context.Context, content Content, target string, policy ConflictPolicy, dst extractTarget) error {
+	format, ok := DetectFormat(content.Name)
+	if !ok {
+		return pathError("unarchive", content.Name, ErrUnknownFormat)
+	}
+
+	if policy == ConflictRename {
+		return pathError("unarchive", target, errors.ErrUnsupported)
+	}
+
+	e := &extractor{
+		ctx:      ctx,
+		target:   target,
+		policy:   policy,
+		dst:      dst,
+		dirModes: make(map[string]fs.FileMode),
+		safeDirs: make(map[string]struct{}),
+	}
+	if format == FormatZip {
+		return e.unzip(content)
+	}
+
+	return e.untar(content, format)
+}
+
+// archiveEntry 压缩包中的一个条目
+type archiveEntry struct {
+	name string
+	mode fs.FileMode
+	link string
+}
+
+// extractor 不同格式共用的解压流程，先校验全部条目，再逐个写入
+type extractor struct {
+	ctx    context.Context
+	target string
+	policy ConflictPolicy
+	dst    extractTarget
+	// keepMode 是否恢复权限位，zip 常见于 Windows，权限位不可靠
+	keepMode bool
+	// dirModes 目录权限在全部写入后再设置，避免只读目录无法写入子文件
+	dirModes map[string]fs.FileMode
+	// safeDirs 已经确认不是软链接的上级目录，避免重复 Lstat
+	safeDirs map[string]struct{}
+}
+
+// check 校验所有条目的路径以及软链接，默认策略下同时检查冲突，任何问题都在写入前返回
+func (e *extractor) check(entries []archiveEntry) error {
+	links := make(map[string]struct{})
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		dest, err := extractPath(e.target, entry.name)
+		if err != nil {
+			return err
+		}
+		paths[i] = dest
+
+		if entry.mode&fs.ModeSymlink != 0 {
+			if err = checkLink(e.target, dest, entry.link); err != nil {
+				return err
+			}
+			links[dest] = struct{}{}
+		}
+	}
+
+	// 禁止写入压缩包中软链接下的路径，多个链接组合后可能指向解压目录以外的位置
+	for _, dest := range paths {
+		for dir := filepath.Dir(dest); within(e.target, dir) && dir != filepath.Clean(e.target); dir = filepath.Dir(dir) {
+			if _, ok := links[dir]; ok {
+				return pathError("unarchive", dest, ErrUnsafePath)
+			}
+		}
+
+		if err := e.checkParents(dest); err != nil {
+			return err
+		}
+	}
+
+	if e.policy == "" || e.policy == ConflictError {
+		for i, entry := range entries {
+			if err := checkConflict(e.dst, paths[i], entry.mode.IsDir()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkParents 解压目录下已经存在的软链接可能指向解压目录以外的位置，拒绝经过软链接写入
+func (e *extractor) checkParents(dest string) error {
+	root := filepath.Clean(e.target)
+	var checked []string
+	for dir := filepath.Dir(dest); dir != root && within(root, dir); dir = filepath.Dir(dir) {
+		if _, ok := e.safeDirs[dir]; ok {
+			break
+		}
+
+		info, err := e.dst.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			return pathError("unarchive", dest, ErrUnsafePath)
+		}
+		checked = append(checked, dir)
+	}
+
+	for _, dir := range checked {
+		e.safeDirs[dir] = struct{}{}
+	}
+	return nil
+}
+
+// write 按照冲突策略写入单个条目，r 为普通文件的内容
+func (e *extractor) write(entry archiveEntry, r io.Reader) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+
+	dest, err := extractPath(e.target, entry.name)
+	if err != nil {
+		return err
+	}
+
+	symlinks, canLink := e.dst.(symlinkTarget)
+	switch {
+	case entry.mode.IsDir(), entry.mode.IsRegular():
+	case entry.mode&fs.ModeSymlink != 0 && canLink:
+	default:
+		slog.Warn("跳过压缩包中不支持的文件类型", slog.String("name", entry.name), slog.String("mode", entry.mode.String()))
+		return nil
+	}
+
+	ok, err := resolveConflict(e.dst, dest, entry.mode, e.policy)
+	if err != nil || !ok {
+		return err
+	}
+
+	switch {
+	case entry.mode.IsDir():
+		if err = e.dst.MkdirAll(dest); err != nil {
+			return err
+		}
+		if e.keepMode {
+			e.dirModes[dest] = entry.mode.Perm()
+		}
+		return nil
+	case entry.mode.IsRegular():
+		if err = extractFile(e.dst, dest, r); err != nil {
+			return err
+		}
+	default:
+		if err = e.dst.MkdirAll(filepath.Dir(dest)); err != nil {
+			return err
+		}
+		return symlinks.Symlink(entry.link, dest)
+	}
+
+	return e.chmod(dest, entry.mode.Perm())
+}
+
+// finish 设置目录权限，子目录优先，避免父目录先变为只读
+func (e *extractor) finish() error {
+	dirs := make([]string, 0, len(e.dirModes))
+	for dir := range e.dirModes {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+
+	for _, dir := range dirs {
+		if err := e.chmod(dir, e.dirModes[dir]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chmod 只恢复普通权限位，不保留 setuid 等特殊权限，避免通过上传的压缩包提权
+func (e *extractor) chmod(path string, perm fs.FileMode) error {
+	target, ok := e.dst.(chmodTarget)
+	if !e.keepMode || !ok {
+		return nil
+	}
+
+	return target.Chmod(path, perm)
+}
+
+// unzip 通过 ReaderAt 按需读取压缩包，避免整个压缩包加载到内存
+func (e *extractor) unzip(content Content) error {
+	readerAt, ok := content.ReadSeekCloser.(io.ReaderAt)
+	if !ok {
+		return pathError("unarchive", content.Name, errors.ErrUnsupported)
+	}
+
+	reader, err := zip.NewReader(readerAt, content.Size)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]archiveEntry, len(reader.File))
+	for i, file := range reader.File {
+		entries[i] = archiveEntry{name: file.Name, mode: file.Mode()}
+		// zip 中的软链接内容为链接路径，与其他实现保持一致直接跳过
+		if entries[i].mode&fs.ModeSymlink != 0 {
+			entries[i].mode = fs.ModeIrregular
+		}
+	}
+
+	if err = e.check(entries); err != nil {
+		return err
+	}
+
+	if err = e.dst.MkdirAll(e.target); err != nil {
+		return err
+	}
+
+	for i, file := range reader.File {
+		if err = e.writeZipFile(entries[i], file); err != nil {
+			return err
+		}
+	}
+
+	return e.finish()
+}
+
+func (e *extractor) writeZipFile(entry archiveEntry, file *zip.File) error {
+	if !entry.mode.IsRegular() {
+		return e.write(entry, nil)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return e.write(entry, src)
+}
+
+func extractFile(dst extractTarget, dest string, src io.Reader) error {
+	if err := dst.MkdirAll(filepath.Dir(dest)); err != nil {
+		return err
+	}
+
+	w, err := dst.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(w, src); err != nil {
+		if aw, ok := w.(abortWriter); ok {
+			_ = aw.CloseWithError(err)
+		} else {
+			_ = w.Close()
+		}
+		return err
+	}
+
+	return w.Close()
+}
+
+// extractPath 计算压缩包内文件的解压路径，拒绝绝对路径以及跳出解压目录的路径
+func extractPath(target, name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if filepath.IsAbs(name) {
+		return "", pathError("unarchive", name, ErrUnsafePath)
+	}
+
+	dest := filepath.Join(target, name)
+	if !within(target, dest) {
+		return "", pathError("unarchive", name, ErrUnsafePath)
+	}
+
+	return dest, nil
+}
+
+// checkLink 软链接只能指向解压目录内部，拒绝绝对路径
+func checkLink(target, dest, link string) error {
+	if link == "" || filepath.IsAbs(link) || !within(target, filepath.Join(filepath.Dir(dest), link)) {
+		return pathError("unarchive", dest+" -> "+link, ErrUnsafePath)
+	}
+
+	return nil
+}
+
+// within 判断 path 是否为 dir 或者 dir 下的路径
+func within(dir, path string) bool {
+	dir, path = filepath.Clean(dir), filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// checkConflict 目录可以合并，其余已存在的情况均视为冲突
+func checkConflict(dst extractTarget, dest string, isDir bool) error {
+	info, err := dst.Lstat(dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case isDir && info.IsDir():
+		return nil
+	default:
+		return pathError("unarchive", dest, fs.ErrExist)
+	}
+}
+
+// resolveConflict 按照冲突策略处理已存在的文件，返回 false 表示跳过
+func resolveConflict(dst extractTarget, dest string, mode fs.FileMode, policy ConflictPolicy) (bool, error) {
+	info, err := dst.Lstat(dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return true, nil
+	case err != nil:
+		return false, err
+	case mode.IsDir() && info.IsDir():
+		return true, nil
+	}
+
+	switch policy {
+	case ConflictSkip:
+		return false, nil
+	case ConflictOverwrite:
+		// 同为普通文件时直接覆盖写入，其余情况先删除，避免通过软链接写入其他位置
+		if !mode.IsRegular() || !info.Mode().IsRegular() {
+			if err = dst.RemoveAll(dest); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return false, pathError("unarchive", dest, fs.ErrExist)
+	}
+}
+
+// fileStat 没有原生 fs.FileInfo 的存储使用，例如对象存储
+type fileStat struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (s fileStat) Name() string       { return s.name }
+func (s fileStat) Size() int64        { return s.size }
+func (s fileStat) Mode() fs.FileMode  { return s.mode }
+func (s fileStat) ModTime() time.Time { return s.modTime }
+func (s fileStat) IsDir() bool        { return s.mode.IsDir() }
+func (s fileStat) Sys() any           { return nil }
@@ -0,0 +1,301 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// modeChange 根据当前的权限计算新的权限，isDir 用于处理符号模式中的 X
+type modeChange func(perm uint32, isDir bool) uint32
+
+// parseMode 解析 chmod 的模式，支持八进制例如 755、4750，以及符号模式例如 u+x,g-w,o=、a+rX
+// 与 chmod 命令不同，没有指定 ugoa 时不受 umask 影响，等同于 a
+func parseMode(spec string) (modeChange, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("mode is required")
+	}
+
+	if spec[0] >= '0' && spec[0] <= '7' {
+		bits, err := strconv.ParseUint(spec, 8, 32)
+		if err != nil || bits > 0o7777 {
+			return nil, fmt.Errorf("invalid mode %q", spec)
+		}
+		return func(uint32, bool) uint32 { return uint32(bits) }, nil
+	}
+
+	var changes []modeChange
+	for _, clause := range strings.Split(spec, ",") {
+		change, err := parseClause(clause)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode %q: %w", spec, err)
+		}
+		changes = append(changes, change)
+	}
+
+	return func(perm uint32, isDir bool) uint32 {
+		for _, change := range changes {
+			perm = change(perm, isDir)
+		}
+		return perm
+	}, nil
+}
+
+// whoBits u、g、o 对应的权限位，包含 setuid、setgid 以及 sticky 位
+var whoBits = map[byte]uint32{'u': 0o4700, 'g': 0o2070, 'o': 0o1007, 'a': 0o7777}
+
+// parseClause 解析 [ugoa]*([-+=][rwxXst]*|[-+=][ugo])+
+func parseClause(clause string) (modeChange, error) {
+	var who uint32
+	i := 0
+	for ; i < len(clause) && whoBits[clause[i]] != 0; i++ {
+		who |= whoBits[clause[i]]
+	}
+
+	if who == 0 {
+		who = 0o7777
+	}
+	if i == len(clause) {
+		return nil, fmt.Errorf("missing operator in %q", clause)
+	}
+
+	var changes []modeChange
+	for i < len(clause) {
+		op := clause[i]
+		if op != '+' && op != '-' && op != '=' {
+			return nil, fmt.Errorf("unexpected %q in %q", op, clause)
+		}
+
+		j := i + 1
+		for j < len(clause) && !strings.ContainsRune("+-=", rune(clause[j])) {
+			j++
+		}
+
+		perms, err := parsePerms(clause[i+1 : j])
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, applyOp(op, who, perms))
+		i = j
+	}
+
+	return func(perm uint32, isDir bool) uint32 {
+		for _, change := range changes {
+			perm = change(perm, isDir)
+		}
+		return perm
+	}, nil
+}
+
+// parsePerms 返回的函数根据当前的权限计算需要修改的权限位，X 以及 ugo 依赖当前的权限
+func parsePerms(perms string) (func(perm uint32, isDir bool) uint32, error) {
+	var bits uint32
+	execIfAny, copyFrom := false, -1
+	for _, c := range perms {
+		switch c {
+		case 'r':
+			bits |= 0o444
+		case 'w':
+			bits |= 0o222
+		case 'x':
+			bits |= 0o111
+		case 'X':
+			execIfAny = true
+		case 's':
+			bits |= 0o6000
+		case 't':
+			bits |= 0o1000
+		case 'u', 'g', 'o':
+			if len(perms) != 1 {
+				return nil, fmt.Errorf("%q can not be combined with other permissions", c)
+			}
+			copyFrom = strings.IndexRune("ugo", c)
+		default:
+			return nil, fmt.Errorf("unknown permission %q", c)
+		}
+	}
+
+	return func(perm uint32, isDir bool) uint32 {
+		if copyFrom >= 0 {
+			// 复制 u、g、o 其中一组的 rwx 到所有组
+			rwx := perm >> (3 * (2 - copyFrom)) & 0o7
+			return rwx<<6 | rwx<<3 | rwx
+		}
+		if execIfAny && (isDir || perm&0o111 != 0) {
+			return bits | 0o111
+		}
+		return bits
+	}, nil
+}
+
+func applyOp(op byte, who uint32, perms func(perm uint32, isDir bool) uint32) modeChange {
+	return func(perm uint32, isDir bool) uint32 {
+		bits := perms(perm, isDir) & who
+		switch op {
+		case '+':
+			return perm | bits
+		case '-':
+			return perm &^ bits
+		default:
+			// = 清空 who 对应的权限位后再设置，目录的 setuid 以及 setgid 保持不变，与 chmod 一致
+			clear := who
+			if isDir {
+				clear &^= 0o6000
+			}
+			return perm&^clear | bits
+		}
+	}
+}
+
+// unixPerm 转换为 unix 的权限位，包含 setuid、setgid 以及 sticky 位
+func unixPerm(mode fs.FileMode) uint32 {
+	perm := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		perm |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		perm |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+
+	return perm
+}
+
+// fileMode unixPerm 的逆操作
+func fileMode(perm uint32) fs.FileMode {
+	mode := fs.FileMode(perm & 0o777)
+	if perm&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if perm&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if perm&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	return mode
+}
+
+// treeFS 修改权限以及属主时遍历需要的操作，ReadDir 返回的条目不跟随软链接
+type treeFS interface {
+	Stat(path string) (fs.FileInfo, error)
+	ReadDir(path string) ([]fs.FileInfo, error)
+}
+
+// walkTree 对 items 执行 fn，recursive 时继续处理目录下的条目
+// 与 chmod -R 一致，遍历时遇到的软链接直接跳过，避免修改链接指向的其他位置
+func walkTree(ctx context.Context, src treeFS, items []Item, recursive bool, fn func(p string, info fs.FileInfo) error) error {
+	var walk func(p string, info fs.FileInfo) error
+	walk = func(p string, info fs.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(p, info); err != nil {
+			return err
+		}
+
+		if !recursive || !info.IsDir() {
+			return nil
+		}
+
+		entries, err := src.ReadDir(p)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.Mode()&fs.ModeSymlink != 0 {
+				continue
+			}
+			if err = walk(path.Join(p, entry.Name()), entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, item := range items {
+		info, err := src.Stat(item.Path)
+		if err != nil {
+			return err
+		}
+
+		if err = walk(path.Clean(item.Path), info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chmodTree 按照模式修改 items 的权限，chmod 由各个实现提供
+func chmodTree(ctx context.Context, src treeFS, items []Item, mode string, recursive bool, chmod func(p string, mode fs.FileMode) error) error {
+	change, err := parseMode(mode)
+	if err != nil {
+		return err
+	}
+
+	return walkTree(ctx, src, items, recursive, func(p string, info fs.FileInfo) error {
+		return chmod(p, fileMode(change(unixPerm(info.Mode()), info.IsDir())))
+	})
+}
+
+// chownTree 修改 items 的属主以及属组，owner 或者 group 为空时保持不变
+// 名称通过 names 解析，也可以直接使用数字 id
+func chownTree(ctx context.Context, src treeFS, items []Item, owner, group string, recursive bool, names *accounts,
+	chown func(p string, uid, gid int) error) error {
+	if owner == "" && group == "" {
+		return fmt.Errorf("owner or group is required")
+	}
+
+	uid, err := lookupId(names.users, owner, "user")
+	if err != nil {
+		return err
+	}
+	gid, err := lookupId(names.groups, group, "group")
+	if err != nil {
+		return err
+	}
+
+	return walkTree(ctx, src, items, recursive, func(p string, info fs.FileInfo) error {
+		u, g, ok := fileOwner(info)
+		if !ok && (uid < 0 || gid < 0) {
+			return pathError("chown", p, fs.ErrInvalid)
+		}
+		if uid >= 0 {
+			u = uint32(uid)
+		}
+		if gid >= 0 {
+			g = uint32(gid)
+		}
+
+		return chown(p, int(u), int(g))
+	})
+}
+
+// lookupId 名称为空时返回 -1，表示保持不变
+func lookupId(ids map[uint32]string, name, kind string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return int(id), nil
+	}
+
+	for id, n := range ids {
+		if n == name {
+			return int(id), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown %s %q", kind, name)
+}
@@ -0,0 +1,185 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+)
+
+// maxRenameAttempts 重命名时最多尝试的序号
+const maxRenameAttempts = 1000
+
+type lstatFS interface {
+	Lstat(path string) (fs.FileInfo, error)
+}
+
+// copyTarget 复制时处理目标冲突需要的操作
+type copyTarget interface {
+	lstatFS
+	RemoveAll(path string) error
+}
+
+// copyFunc 复制单个条目，merge 为 true 时目标目录已存在，需要合并
+type copyFunc func(from, to string, info fs.FileInfo, merge bool) error
+
+// copyItems 按照冲突策略计算每个条目的目标路径后交给 fn 复制
+func copyItems(ctx context.Context, src lstatFS, dst copyTarget, items []Item, target string, policy ConflictPolicy, fn copyFunc) error {
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := src.Lstat(item.Path)
+		if err != nil {
+			return err
+		}
+
+		from := path.Clean(item.Path)
+		to, merge, err := copyDest(dst, path.Join(target, path.Base(from)), info, policy)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case to == "":
+			continue
+		case to == from && !info.IsDir():
+			// 覆盖自身等同于不做任何操作，直接写入会先清空源文件
+			continue
+		case info.IsDir() && within(from, to):
+			return pathError("copy", to, fs.ErrInvalid)
+		}
+
+		if err = fn(from, to, info, merge); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyDest 返回空字符串表示跳过，目录之间覆盖时合并内容
+func copyDest(dst copyTarget, dest string, info fs.FileInfo, policy ConflictPolicy) (string, bool, error) {
+	existing, err := dst.Lstat(dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return dest, false, nil
+	case err != nil:
+		return "", false, err
+	}
+
+	switch policy {
+	case ConflictSkip:
+		return "", false, nil
+	case ConflictRename:
+		dest, err = uniquePath(dst, dest, info.IsDir())
+		return dest, false, err
+	case ConflictOverwrite:
+		if info.IsDir() && existing.IsDir() {
+			return dest, true, nil
+		}
+
+		// 同为普通文件时直接覆盖写入，其余情况先删除
+		if !info.Mode().IsRegular() || !existing.Mode().IsRegular() {
+			if err = dst.RemoveAll(dest); err != nil {
+				return "", false, err
+			}
+		}
+		return dest, false, nil
+	default:
+		return "", false, pathError("copy", dest, fs.ErrExist)
+	}
+}
+
+// uniquePath 在名称后追加序号，例如 app.conf 依次尝试 app-1.conf、app-2.conf
+func uniquePath(dst lstatFS, dest string, isDir bool) (string, error) {
+	dir, name := path.Split(dest)
+	ext := ""
+	if stem := TrimArchiveExt(name); !isDir && stem != "" {
+		name, ext = stem, name[len(stem):]
+	}
+
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := path.Join(dir, fmt.Sprintf("%s-%d%s", name, i, ext))
+		_, err := dst.Lstat(candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", pathError("copy", dest, fs.ErrExist)
+}
+
+// copyTree 读取源文件后写入目标位置，适用于没有服务端复制能力的场景
+// 支持软链接的存储复制软链接本身，否则复制软链接指向的内容
+func copyTree(ctx context.Context, src archiveSource, dst extractTarget, from, to string, info fs.FileInfo, merge bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if merge {
+		ok, err := resolveConflict(dst, to, info.Mode(), ConflictOverwrite)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	mode := info.Mode()
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		if links, ok := dst.(symlinkTarget); ok {
+			link, err := src.ReadLink(from)
+			if err != nil {
+				return err
+			}
+			return links.Symlink(link, to)
+		}
+
+		target, err := src.Stat(from)
+		if err != nil {
+			return err
+		}
+		return copyTree(ctx, src, dst, from, to, target, false)
+	case mode.IsDir():
+		if err := dst.MkdirAll(to); err != nil {
+			return err
+		}
+
+		entries, err := src.ReadDir(from)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err = copyTree(ctx, src, dst, path.Join(from, entry.Name()), path.Join(to, entry.Name()), entry, merge)
+			if err != nil {
+				return err
+			}
+		}
+	case mode.IsRegular():
+		file, err := src.Open(from)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err = extractFile(dst, to, file); err != nil {
+			return err
+		}
+	default:
+		slog.Warn("跳过不支持复制的文件类型", slog.String("path", from), slog.String("mode", mode.String()))
+		return nil
+	}
+
+	if target, ok := dst.(chmodTarget); ok {
+		return target.Chmod(to, mode.Perm())
+	}
+
+	return nil
+}
@@ -0,0 +1,659 @@
+package finder_test
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"github.com/Duke1616/vuefinder-go/pkg/finder/findertest"
+	"github.com/pkg/sftp"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSftpFinder(t *testing.T) {
+	findertest.Run(t, func(t *testing.T) (finder.Finder, string) {
+		dir := t.TempDir()
+		return finder.NewSftpFinder(newSftpClient(t, dir)), dir
+	})
+}
+
+// TestSftpGrepRelativeRoot 远程执行的 find 会把以 - 开头的路径当作表达式，例如 -delete
+func TestSftpGrepRelativeRoot(t *testing.T) {
+	f := finder.NewSftpFinder(newSftpClient(t, t.TempDir()))
+	for _, root := range []string{"-delete", "data"} {
+		if _, err := f.Grep(context.Background(), root, finder.GrepOptions{Pattern: "a"}); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Grep %s = %v, want %v", root, err, fs.ErrInvalid)
+		}
+	}
+}
+
+func TestLocalFinder(t *testing.T) {
+	findertest.Run(t, func(t *testing.T) (finder.Finder, string) {
+		root := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(root, "data", "test"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return finder.NewLocalFinder(root), "/data/test"
+	})
+}
+
+func TestMemoryFinder(t *testing.T) {
+	findertest.Run(t, func(t *testing.T) (finder.Finder, string) {
+		f := finder.NewMemoryFinder()
+		if err := f.NewFolder(context.Background(), "/data", "test"); err != nil {
+			t.Fatal(err)
+		}
+		return f, "/data/test"
+	})
+}
+
+func TestS3Finder(t *testing.T) {
+	findertest.Run(t, func(t *testing.T) (finder.Finder, string) {
+		client, _ := newS3Client(t, "data")
+		f := finder.NewS3Finder(client)
+		if err := f.NewFolder(context.Background(), "/data", "test"); err != nil {
+			t.Fatal(err)
+		}
+		return f, "/data/test"
+	})
+}
+
+func TestJailFinder(t *testing.T) {
+	t.Run("sftp", func(t *testing.T) {
+		findertest.Run(t, func(t *testing.T) (finder.Finder, string) {
+			dir := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(dir, "jail", "data", "test"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			return finder.NewJailFinder(finder.NewSftpFinder(newSftpClient(t, dir)), filepath.Join(dir, "jail")), "/data/test"
+		})
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		findertest.Run(t, func(t *testing.T) (finder.Finder, string) {
+			f := finder.NewMemoryFinder()
+			if err := f.NewFolder(context.Background(), "/jail/data", "test"); err != nil {
+				t.Fatal(err)
+			}
+			return finder.NewJailFinder(f, "/jail"), "/data/test"
+		})
+	})
+}
+
+func TestJailEscape(t *testing.T) {
+	runHostFinders(t, func(t *testing.T, inner finder.Finder, dir, root string) {
+		for file, content := range map[string]string{"secret/key": "secret", "jail/data/a.txt": "inside secret"} {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for link, target := range map[string]string{"out": filepath.Join(dir, "secret"), "up": "../../secret", "in": "a.txt"} {
+			if err := os.Symlink(target, filepath.Join(dir, "jail", "data", link)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		f := finder.NewJailFinder(inner, filepath.Join(root, "jail"))
+		ctx := context.Background()
+
+		for _, p := range []string{"/../secret/key", "/data/../../secret/key", "/data/out/key", "/data/up/key"} {
+			if _, err := f.Download(ctx, p); !errors.Is(err, finder.ErrOutsideRoot) {
+				t.Errorf("Download %s = %v, want ErrOutsideRoot", p, err)
+			}
+		}
+		if err := f.Save(ctx, "/data/out/key", "changed"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Save through link = %v, want permission error", err)
+		}
+		if err := f.Rename(ctx, "/data/a.txt", "../../a.txt", "/data"); !errors.Is(err, finder.ErrOutsideRoot) {
+			t.Errorf("Rename out of root = %v, want ErrOutsideRoot", err)
+		}
+		if _, err := f.Index(ctx, "data", "/data/out"); !errors.Is(err, finder.ErrOutsideRoot) {
+			t.Errorf("Index link = %v, want ErrOutsideRoot", err)
+		}
+		err := f.Archive(ctx, []finder.Item{{Path: "/data", Type: finder.DIR}}, "data.zip", "/", "")
+		if !errors.Is(err, finder.ErrOutsideRoot) {
+			t.Errorf("Archive with escaping link = %v, want ErrOutsideRoot", err)
+		}
+
+		// 解压目录下的 out 指向根目录以外，压缩包中的 out/key 会经过链接写入
+		writeZip(t, filepath.Join(dir, "jail", "key.zip"), "out/key")
+		if err = f.Unarchive(ctx, "/key.zip", "/data", finder.ConflictOverwrite); !errors.Is(err, finder.ErrOutsideRoot) {
+			t.Errorf("Unarchive through escaping link = %v, want ErrOutsideRoot", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "secret", "key")); string(data) != "secret" {
+			t.Errorf("secret/key = %q, want it unchanged", data)
+		}
+
+		storage, err := f.Index(ctx, "data", "/data")
+		if err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		if storage.Dirname != "/data" || !slices.Equal(storage.Storages, []string{"data"}) {
+			t.Errorf("Index = %s %v, want /data [data]", storage.Dirname, storage.Storages)
+		}
+		want := map[string]finder.FileInfo{
+			"a.txt": {Path: "/data/a.txt"},
+			"in":    {Path: "/data/in", LinkTarget: "/data/a.txt"},
+			"out":   {Path: "/data/out", BrokenLink: true},
+			"up":    {Path: "/data/up", BrokenLink: true},
+		}
+		for _, file := range storage.Files {
+			if w, ok := want[file.Basename]; ok && (file.Path != w.Path || file.LinkTarget != w.LinkTarget || file.BrokenLink != w.BrokenLink) {
+				t.Errorf("%s = %+v, want %+v", file.Basename, file, w)
+			}
+		}
+
+		matches, err := f.Grep(ctx, "/", finder.GrepOptions{Pattern: "secret"})
+		if err != nil {
+			t.Fatalf("Grep: %v", err)
+		}
+		if len(matches) == 0 {
+			t.Error("Grep should find /data/a.txt")
+		}
+		for _, match := range matches {
+			if match.Path != "/data/a.txt" && match.Path != "/data/in" {
+				t.Errorf("Grep matched %s outside of the root", match.Path)
+			}
+		}
+
+		// 删除指向根目录以外的软链接只删除链接本身
+		if err = f.Remove(ctx, []finder.Item{{Path: "/data/out", Type: finder.DIR}}, "/data"); err != nil {
+			t.Fatalf("Remove link: %v", err)
+		}
+		assertFile(t, filepath.Join(dir, "secret", "key"), "secret")
+		if err = f.RemoveDir(ctx, "/"); !errors.Is(err, finder.ErrOutsideRoot) {
+			t.Errorf("RemoveDir root = %v, want ErrOutsideRoot", err)
+		}
+	})
+}
+
+// TestTarKeepsModeAndSymlinks tar 格式需要保留权限位以及软链接，只有本机文件系统以及 SFTP 支持
+func TestTarKeepsModeAndSymlinks(t *testing.T) {
+	runHostFinders(t, func(t *testing.T, f finder.Finder, dir, root string) {
+		if err := os.MkdirAll(filepath.Join(dir, "data", "src", "bin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data", "src", "bin", "run.sh"), []byte("#!/bin/sh"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("bin/run.sh", filepath.Join(dir, "data", "src", "run")); err != nil {
+			t.Fatal(err)
+		}
+
+		base := filepath.Join(root, "data")
+		ctx := context.Background()
+		err := f.Archive(ctx, []finder.Item{{Path: filepath.Join(base, "src"), Type: finder.DIR}}, "src", base, finder.FormatTarGz)
+		if err != nil {
+			t.Fatalf("Archive: %v", err)
+		}
+		if err = f.Unarchive(ctx, filepath.Join(base, "src.tar.gz"), filepath.Join(base, "out"), ""); err != nil {
+			t.Fatalf("Unarchive: %v", err)
+		}
+
+		info, err := os.Stat(filepath.Join(dir, "data", "out", "src", "bin", "run.sh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0750 {
+			t.Errorf("run.sh mode = %v, want %v", info.Mode().Perm(), os.FileMode(0750))
+		}
+
+		// 测试使用的 SFTP 服务端会将相对路径转换为工作目录下的绝对路径，只校验结尾部分
+		link, err := os.Readlink(filepath.Join(dir, "data", "out", "src", "run"))
+		if err != nil || !strings.HasSuffix(link, "bin/run.sh") {
+			t.Errorf("Readlink = %q, %v, want bin/run.sh", link, err)
+		}
+	})
+}
+
+// TestUnarchiveThroughSymlink 解压目录下已经存在指向其他位置的软链接时，不能经过软链接写入
+func TestUnarchiveThroughSymlink(t *testing.T) {
+	runHostFinders(t, func(t *testing.T, f finder.Finder, dir, root string) {
+		for _, d := range []string{"outside", "data/target"} {
+			if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.Symlink(filepath.Join(dir, "outside"), filepath.Join(dir, "data", "target", "foo")); err != nil {
+			t.Fatal(err)
+		}
+
+		writeZip(t, filepath.Join(dir, "data", "a.zip"), "ok.txt", "foo/passwd")
+
+		base := filepath.Join(root, "data")
+		for _, policy := range []finder.ConflictPolicy{"", finder.ConflictOverwrite, finder.ConflictSkip} {
+			err := f.Unarchive(context.Background(), filepath.Join(base, "a.zip"), filepath.Join(base, "target"), policy)
+			if !errors.Is(err, finder.ErrUnsafePath) {
+				t.Errorf("Unarchive %q = %v, want %v", policy, err, finder.ErrUnsafePath)
+			}
+		}
+
+		// 校验在写入之前完成，其他文件同样没有写入
+		for _, file := range []string{"outside/passwd", "data/target/ok.txt"} {
+			if _, err := os.Lstat(filepath.Join(dir, file)); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s should not be written: %v", file, err)
+			}
+		}
+	})
+}
+
+// TestSymlinks 软链接在文件所在的存储上解析，删除时只删除软链接本身
+func TestSymlinks(t *testing.T) {
+	runHostFinders(t, func(t *testing.T, f finder.Finder, dir, root string) {
+		if err := os.MkdirAll(filepath.Join(dir, "data", "real"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data", "real", "a.txt"), []byte("abc"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for link, target := range map[string]string{"dir": "real", "file": "real/a.txt", "broken": "missing"} {
+			if err := os.Symlink(target, filepath.Join(dir, "data", link)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		base := filepath.Join(root, "data")
+		ctx := context.Background()
+		storage, err := f.Index(ctx, "data", base)
+		if err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+
+		want := map[string]finder.FileInfo{
+			"dir":    {Type: finder.DIR, LinkTarget: "real"},
+			"file":   {Type: finder.FILE, LinkTarget: "real/a.txt", FileSize: 3},
+			"broken": {Type: finder.FILE, LinkTarget: "missing", BrokenLink: true},
+		}
+		for _, file := range storage.Files {
+			w, ok := want[file.Basename]
+			if !ok {
+				continue
+			}
+			delete(want, file.Basename)
+			if file.Type != w.Type || file.LinkTarget != w.LinkTarget || file.BrokenLink != w.BrokenLink ||
+				w.FileSize > 0 && file.FileSize != w.FileSize {
+				t.Errorf("%s = %+v, want %+v", file.Basename, file, w)
+			}
+		}
+		if len(want) > 0 {
+			t.Errorf("Index is missing %v", want)
+		}
+
+		// 进入指向目录的软链接
+		storage, err = f.Index(ctx, "data", filepath.Join(base, "dir"))
+		if err != nil {
+			t.Fatalf("Index link: %v", err)
+		}
+		if len(storage.Files) != 3 || storage.Files[2].Path != filepath.Join(base, "dir", "a.txt") {
+			t.Errorf("Index link = %+v, want . .. and a.txt under the link", storage.Files)
+		}
+
+		if err = f.RemoveDir(ctx, filepath.Join(base, "dir")); err != nil {
+			t.Fatalf("RemoveDir: %v", err)
+		}
+		assertFile(t, filepath.Join(dir, "data", "real", "a.txt"), "abc")
+		if _, err = os.Lstat(filepath.Join(dir, "data", "dir")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("link still exists after RemoveDir: %v", err)
+		}
+	})
+}
+
+// TestOwnerAndMode 权限位以及属主从文件属性获取，名称从 /etc/passwd 以及 /etc/group 解析，同样用于 Chown
+func TestOwnerAndMode(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	runHostFinders(t, func(t *testing.T, f finder.Finder, dir, root string) {
+		if err := os.MkdirAll(filepath.Join(dir, "data"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for file, mode := range map[string]os.FileMode{"public.txt": 0644, "secret.txt": 0640} {
+			if err := os.WriteFile(filepath.Join(dir, "data", file), nil, mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(filepath.Join(dir, "data", file), mode); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		base := filepath.Join(root, "data")
+		storage, err := f.Index(context.Background(), "data", base)
+		if err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+
+		for file, want := range map[string][3]string{
+			"public.txt": {"-rw-r--r--", "0644", "public"},
+			"secret.txt": {"-rw-r-----", "0640", "private"},
+		} {
+			info := mustFind(t, storage.Files, file)
+			if got := [3]string{info.Mode, info.Perm, info.Visibility}; got != want {
+				t.Errorf("%s mode = %v, want %v", file, got, want)
+			}
+
+			if info.Uid == nil || strconv.Itoa(int(*info.Uid)) != current.Uid {
+				t.Errorf("%s uid = %v, want %s", file, info.Uid, current.Uid)
+			}
+			if info.Owner != current.Username {
+				t.Errorf("%s owner = %q, want %q", file, info.Owner, current.Username)
+			}
+		}
+
+		// 非 root 用户只能修改为自己，名称以及数字 id 都可以使用
+		items := []finder.Item{{Path: filepath.Join(base, "public.txt"), Type: finder.FILE}}
+		if err = f.Chown(context.Background(), items, current.Username, current.Gid, false); err != nil {
+			t.Errorf("Chown: %v", err)
+		}
+		if err = f.Chown(context.Background(), items, "no-such-user", "", false); err == nil {
+			t.Error("Chown to an unknown user should fail")
+		}
+	})
+}
+
+func mustFind(t *testing.T, files []finder.FileInfo, name string) finder.FileInfo {
+	t.Helper()
+	for _, file := range files {
+		if file.Basename == name {
+			return file
+		}
+	}
+
+	t.Fatalf("%s not found", name)
+	return finder.FileInfo{}
+}
+
+func TestReadOnlyFinder(t *testing.T) {
+	ctx := context.Background()
+	memory := finder.NewMemoryFinder()
+	if err := memory.Put(ctx, "/data/a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	f := finder.NewReadOnlyFinder(memory)
+	items := []finder.Item{{Path: "/data/a.txt", Type: finder.FILE}}
+	for name, fn := range map[string]func() error{
+		"Save":      func() error { return f.Save(ctx, "/data/a.txt", "b") },
+		"Put":       func() error { return f.Put(ctx, "/data/b.txt", strings.NewReader("b"), 1) },
+		"Upload":    func() error { return f.Upload(ctx, nil, "/data", "b.txt") },
+		"Rename":    func() error { return f.Rename(ctx, "/data/a.txt", "b.txt", "/data") },
+		"NewFile":   func() error { return f.NewFile(ctx, "/data", "b.txt") },
+		"NewFolder": func() error { return f.NewFolder(ctx, "/data", "b") },
+		"Remove":    func() error { return f.Remove(ctx, items, "/data") },
+		"RemoveDir": func() error { return f.RemoveDir(ctx, "/data") },
+		"Move":      func() error { return f.Move(ctx, items, "/data/b") },
+		"Copy":      func() error { return f.Copy(ctx, items, "/data/b", finder.ConflictError) },
+		"Archive":   func() error { return f.Archive(ctx, items, "a.zip", "/data", "") },
+		"Unarchive": func() error { return f.Unarchive(ctx, "/data/a.zip", "/data/a", finder.ConflictError) },
+		"Chmod":     func() error { return f.Chmod(ctx, items, "0600", false) },
+	} {
+		if err := fn(); !errors.Is(err, finder.ErrReadOnly) || !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s = %v, want ErrReadOnly", name, err)
+		}
+	}
+
+	content, err := f.Download(ctx, "/data/a.txt")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	_ = content.Close()
+
+	// 内部实现不支持的操作同样保留
+	if c := finder.CapabilitiesOf(memory); c.ReadOnly || !c.Upload || c.Chown {
+		t.Errorf("memory capabilities = %+v", c)
+	}
+	if c := finder.CapabilitiesOf(finder.NewJailFinder(f, "/data")); !c.ReadOnly || c.Upload || c.Remove {
+		t.Errorf("read-only capabilities = %+v", c)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	ctx := context.Background()
+	memory := finder.NewMemoryFinder()
+	if err := memory.Put(ctx, "/data/src/a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.Put(ctx, "/data/src/dir/b.txt", strings.NewReader("b"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "data", "dst"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	local := finder.NewLocalFinder(root)
+	items := []finder.Item{
+		{Path: "/data/src/a.txt", Type: finder.FILE},
+		{Path: "/data/src/dir", Type: finder.DIR},
+	}
+
+	if err := finder.Transfer(ctx, memory, local, items, "/data/dst", "", false); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	assertFile(t, filepath.Join(root, "data", "dst", "a.txt"), "a")
+	assertFile(t, filepath.Join(root, "data", "dst", "dir", "b.txt"), "b")
+
+	// 默认策略下目标已存在时失败
+	if err := finder.Transfer(ctx, memory, local, items, "/data/dst", "", false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Transfer = %v, want %v", err, fs.ErrExist)
+	}
+
+	if err := finder.Transfer(ctx, memory, local, items, "/data/dst", finder.ConflictRename, true); err != nil {
+		t.Fatalf("Transfer move: %v", err)
+	}
+	assertFile(t, filepath.Join(root, "data", "dst", "a-1.txt"), "a")
+	assertFile(t, filepath.Join(root, "data", "dst", "dir-1", "b.txt"), "b")
+
+	storage, err := memory.Index(ctx, "data", "/data/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storage.Files) != 2 {
+		t.Errorf("source still contains %d entries after move, want only . and ..", len(storage.Files)-2)
+	}
+}
+
+// TestTransferSkipsSymlinks 目录中的软链接不会被跟随，避免链接循环以及复制源目录以外的文件
+func TestTransferSkipsSymlinks(t *testing.T) {
+	root := t.TempDir()
+	for file, content := range map[string]string{"data/src/dir/b.txt": "b", "secret/key": "secret"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{"loop": "..", "key": "../../../secret/key", "secret": "../../../secret"} {
+		if err := os.Symlink(target, filepath.Join(root, "data", "src", "dir", link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	memory := finder.NewMemoryFinder()
+	if err := memory.NewFolder(ctx, "/", "data"); err != nil {
+		t.Fatal(err)
+	}
+	items := []finder.Item{{Path: "/data/src/dir", Type: finder.DIR}}
+	if err := finder.Transfer(ctx, finder.NewLocalFinder(root), memory, items, "/data", "", false); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+
+	storage, err := memory.Index(ctx, "data", "/data/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range storage.Files {
+		names = append(names, file.Basename)
+	}
+	if !slices.Equal(names, []string{".", "..", "b.txt"}) {
+		t.Errorf("transferred %v, want only b.txt", names)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	files := []finder.FileInfo{
+		{Type: finder.DIR, Path: "/data/.", Basename: "."},
+		{Type: finder.FILE, Path: "/data/b.log", Basename: "b.log", Extension: "log", FileSize: 3, LastModified: 20},
+		{Type: finder.DIR, Path: "/data/logs", Basename: "logs", LastModified: 30},
+		{Type: finder.FILE, Path: "/data/A.txt", Basename: "A.txt", Extension: "txt", FileSize: 1, LastModified: 10},
+		{Type: finder.FILE, Path: "/data/c.conf", Basename: "c.conf", Extension: "conf", FileSize: 2, LastModified: 40},
+	}
+
+	tests := []struct {
+		name string
+		opts finder.ListOptions
+		want string
+	}{
+		{name: "name", opts: finder.ListOptions{}, want: ".,A.txt,b.log,c.conf,logs"},
+		{name: "size desc", opts: finder.ListOptions{Sort: finder.SortSize, Desc: true}, want: ".,b.log,c.conf,A.txt,logs"},
+		{name: "mtime", opts: finder.ListOptions{Sort: finder.SortModified}, want: ".,A.txt,b.log,logs,c.conf"},
+		{name: "type", opts: finder.ListOptions{Sort: finder.SortType}, want: ".,logs,c.conf,b.log,A.txt"},
+		{name: "offset", opts: finder.ListOptions{Offset: 1, Limit: 2}, want: ".,b.log,c.conf"},
+		{name: "offset beyond end", opts: finder.ListOptions{Offset: 10, Limit: 2}, want: "."},
+	}
+
+	for _, tc := range tests {
+		got, page, err := finder.Paginate(files, tc.opts)
+		if err != nil {
+			t.Fatalf("Paginate %s: %v", tc.name, err)
+		}
+		if names := strings.Join(basenames(got), ","); names != tc.want {
+			t.Errorf("Paginate %s = %s, want %s", tc.name, names, tc.want)
+		}
+		if page.Total != 4 {
+			t.Errorf("Paginate %s total = %d, want 4", tc.name, page.Total)
+		}
+	}
+
+	// 游标翻页期间删除已经返回的条目，不影响下一页的位置
+	opts := finder.ListOptions{Sort: finder.SortName, Limit: 2}
+	_, page, err := finder.Paginate(files, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Cursor = page.NextCursor
+	got, page, err := finder.Paginate(append(files[:1:1], files[2:]...), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := strings.Join(basenames(got), ","); names != ".,c.conf,logs" || page.NextCursor != "" {
+		t.Errorf("Paginate cursor = %s next %q, want .,c.conf,logs without next cursor", names, page.NextCursor)
+	}
+
+	for _, opts := range []finder.ListOptions{
+		{Sort: "owner"},
+		{Offset: -1},
+		{Cursor: "invalid"},
+		{Sort: finder.SortSize, Cursor: page.NextCursor + opts.Cursor},
+	} {
+		if _, _, err = finder.Paginate(files, opts); err == nil {
+			t.Errorf("Paginate %+v should fail", opts)
+		}
+	}
+}
+
+func basenames(files []finder.FileInfo) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Basename)
+	}
+	return names
+}
+
+func assertFile(t *testing.T, file, want string) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", file, data, want)
+	}
+}
+
+// writeZip 生成内容为 names 的 zip 压缩包
+func writeZip(t *testing.T, file string, names ...string) {
+	t.Helper()
+	archive, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(archive)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = errors.Join(zw.Close(), archive.Close()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runHostFinders 分别通过 SFTP 以及本机文件系统访问同一个本机临时目录 dir，用于依赖软链接、权限位等真实文件系统特性的测试
+// root 为 dir 在 Finder 中的路径，SFTP 使用本机绝对路径，本机文件系统使用 /
+func runHostFinders(t *testing.T, fn func(t *testing.T, f finder.Finder, dir, root string)) {
+	factories := map[string]func(t *testing.T, dir string) (finder.Finder, string){
+		"sftp": func(t *testing.T, dir string) (finder.Finder, string) {
+			return finder.NewSftpFinder(newSftpClient(t, dir)), dir
+		},
+		"local": func(t *testing.T, dir string) (finder.Finder, string) { return finder.NewLocalFinder(dir), "/" },
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, root := factory(t, dir)
+			fn(t, f, dir, root)
+		})
+	}
+}
+
+// newSftpClient 启动进程内的 SFTP 服务端，直接操作本机文件系统
+func newSftpClient(t *testing.T, dir string) *sftp.Client {
+	t.Helper()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter}, sftp.WithServerWorkingDirectory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		// 先关闭服务端，客户端才能读取到 EOF 并退出
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return client
+}
@@ -0,0 +1,1004 @@
+// Package findertest 提供 finder.Finder 的一致性测试套件，所有实现都应该通过
+package findertest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Factory 创建一个全新的 Finder 以及测试使用的工作目录
+// 工作目录必须是已存在的绝对路径，并且不是根目录下的第一级目录
+type Factory func(t *testing.T) (finder.Finder, string)
+
+// Run 针对 Factory 创建的 Finder 执行完整的一致性测试
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, f finder.Finder, base string)
+	}{
+		{name: "Index", fn: testIndex},
+		{name: "IndexDotEntries", fn: testIndexDotEntries},
+		{name: "IndexNotExist", fn: testIndexNotExist},
+		{name: "Subfolders", fn: testSubfolders},
+		{name: "Search", fn: testSearch},
+		{name: "SearchRecursive", fn: testSearchRecursive},
+		{name: "SearchInvalid", fn: testSearchInvalid},
+		{name: "Grep", fn: testGrep},
+		{name: "Save", fn: testSave},
+		{name: "Put", fn: testPut},
+		{name: "DownloadSeek", fn: testDownloadSeek},
+		{name: "Upload", fn: testUpload},
+		{name: "UploadNested", fn: testUploadNested},
+		{name: "UploadOverwrite", fn: testUploadOverwrite},
+		{name: "NewFolder", fn: testNewFolder},
+		{name: "Rename", fn: testRename},
+		{name: "RenameExisting", fn: testRenameExisting},
+		{name: "RenameDotEntries", fn: testRenameDotEntries},
+		{name: "RenameInvalidName", fn: testRenameInvalidName},
+		{name: "Move", fn: testMove},
+		{name: "MoveExisting", fn: testMoveExisting},
+		{name: "Copy", fn: testCopy},
+		{name: "CopyConflict", fn: testCopyConflict},
+		{name: "CopyIntoItself", fn: testCopyIntoItself},
+		{name: "Chmod", fn: testChmod},
+		{name: "Remove", fn: testRemove},
+		{name: "RemoveDotEntries", fn: testRemoveDotEntries},
+		{name: "Archive", fn: testArchive},
+		{name: "ArchiveDotEntries", fn: testArchiveDotEntries},
+		{name: "ArchiveFormats", fn: testArchiveFormats},
+		{name: "Unarchive", fn: testUnarchive},
+		{name: "UnarchiveConflict", fn: testUnarchiveConflict},
+		{name: "UnarchiveZipSlip", fn: testUnarchiveZipSlip},
+		{name: "UnarchiveTarSlip", fn: testUnarchiveTarSlip},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, base := factory(t)
+			tc.fn(t, f, base)
+		})
+	}
+}
+
+func testIndex(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustNewFolder(t, f, base, "docs")
+	mustSave(t, f, path.Join(base, "a.txt"), "hello")
+
+	storage, err := f.Index(ctx, adapter(base), base)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	if storage.Adapter != adapter(base) {
+		t.Errorf("Adapter = %q, want %q", storage.Adapter, adapter(base))
+	}
+	if storage.Dirname != base {
+		t.Errorf("Dirname = %q, want %q", storage.Dirname, base)
+	}
+
+	files := withoutDots(storage.Files)
+	if len(files) != 2 {
+		t.Fatalf("Files = %v, want 2 entries", basenames(files))
+	}
+
+	file := mustFind(t, files, "a.txt")
+	if file.Type != finder.FILE || file.Path != path.Join(base, "a.txt") ||
+		file.Extension != "txt" || file.FileSize != 5 || file.Storage != adapter(base) {
+		t.Errorf("a.txt = %+v", file)
+	}
+
+	dir := mustFind(t, files, "docs")
+	if dir.Type != finder.DIR || dir.Path != path.Join(base, "docs") {
+		t.Errorf("docs = %+v", dir)
+	}
+}
+
+func testIndexDotEntries(t *testing.T, f finder.Finder, base string) {
+	mustNewFolder(t, f, base, "sub")
+	sub := path.Join(base, "sub")
+
+	storage, err := f.Index(context.Background(), adapter(base), sub)
+	if err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	if len(storage.Files) != 2 {
+		t.Fatalf("Files = %v, want only . and ..", basenames(storage.Files))
+	}
+
+	dot, dotdot := storage.Files[0], storage.Files[1]
+	if dot.Basename != "." || dot.Type != finder.DIR || dot.Path != sub {
+		t.Errorf(". = %+v", dot)
+	}
+	if dotdot.Basename != ".." || dotdot.Type != finder.DIR || dotdot.Path != base {
+		t.Errorf(".. = %+v", dotdot)
+	}
+}
+
+func testIndexNotExist(t *testing.T, f finder.Finder, base string) {
+	_, err := f.Index(context.Background(), adapter(base), path.Join(base, "missing"))
+	if err == nil {
+		t.Fatal("Index of a missing directory should fail")
+	}
+}
+
+func testSubfolders(t *testing.T, f finder.Finder, base string) {
+	mustNewFolder(t, f, base, "a")
+	mustNewFolder(t, f, base, "b")
+	mustSave(t, f, path.Join(base, "c.txt"), "c")
+
+	folders, err := f.Subfolders(context.Background(), adapter(base), base)
+	if err != nil {
+		t.Fatalf("Subfolders: %v", err)
+	}
+
+	if got := strings.Join(basenames(folders), ","); got != "a,b" {
+		t.Errorf("Subfolders = %s, want a,b", got)
+	}
+}
+
+func testSearch(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "report.log"), "")
+	mustSave(t, f, path.Join(base, "access.log"), "")
+	mustSave(t, f, path.Join(base, "notes.txt"), "")
+
+	storage, err := f.Search(context.Background(), adapter(base), base, finder.SearchOptions{Pattern: ".log"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if got := strings.Join(basenames(storage.Files), ","); got != "access.log,report.log" {
+		t.Errorf("Search = %s, want access.log,report.log", got)
+	}
+}
+
+func testSearchRecursive(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "app.LOG"), "12345")
+	mustSave(t, f, path.Join(base, "logs/access.log"), "1")
+	mustSave(t, f, path.Join(base, "logs/2024/error.log"), "1234567890")
+	mustSave(t, f, path.Join(base, "logs/2024/notes.txt"), "")
+
+	tests := []struct {
+		name string
+		opts finder.SearchOptions
+		want string
+	}{
+		{name: "contains", opts: finder.SearchOptions{Pattern: ".log"}, want: "access.log,error.log"},
+		{name: "ignore case", opts: finder.SearchOptions{Pattern: ".log", IgnoreCase: true}, want: "access.log,app.LOG,error.log"},
+		{name: "glob", opts: finder.SearchOptions{Pattern: "*.LOG", Match: finder.MatchGlob, IgnoreCase: true}, want: "access.log,app.LOG,error.log"},
+		{name: "regex", opts: finder.SearchOptions{Pattern: `^(access|notes)\.`, Match: finder.MatchRegex}, want: "access.log,notes.txt"},
+		{name: "max depth", opts: finder.SearchOptions{Pattern: "log", IgnoreCase: true, MaxDepth: 2}, want: "access.log,app.LOG,logs"},
+		{name: "type", opts: finder.SearchOptions{Type: finder.DIR}, want: "2024,logs"},
+		{name: "size", opts: finder.SearchOptions{MinSize: 2, MaxSize: 5}, want: "app.LOG"},
+		{name: "modified", opts: finder.SearchOptions{ModifiedAfter: 1 << 40}, want: ""},
+	}
+
+	for _, tc := range tests {
+		storage, err := f.Search(context.Background(), adapter(base), base, tc.opts)
+		if err != nil {
+			t.Fatalf("Search %s: %v", tc.name, err)
+		}
+
+		if got := strings.Join(basenames(storage.Files), ","); got != tc.want {
+			t.Errorf("Search %s = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	storage, err := f.Search(context.Background(), adapter(base), base, finder.SearchOptions{Type: finder.FILE, Limit: 2})
+	if err != nil {
+		t.Fatalf("Search limit: %v", err)
+	}
+	if len(storage.Files) != 2 {
+		t.Errorf("Search limit = %v, want 2 entries", basenames(storage.Files))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = f.Search(ctx, adapter(base), base, finder.SearchOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Search with canceled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func testSearchInvalid(t *testing.T, f finder.Finder, base string) {
+	for _, opts := range []finder.SearchOptions{
+		{Pattern: "[", Match: finder.MatchGlob},
+		{Pattern: "(", Match: finder.MatchRegex},
+		{Pattern: "a", Match: "fuzzy"},
+	} {
+		if _, err := f.Search(context.Background(), adapter(base), base, opts); err == nil {
+			t.Errorf("Search %+v should fail", opts)
+		}
+	}
+}
+
+func testGrep(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "app.log"), "start\nERROR disk full\nstop")
+	mustSave(t, f, path.Join(base, "logs/access.log"), "GET /\nerror: timeout\nGET /a.b")
+	mustSave(t, f, path.Join(base, "logs/notes.txt"), "no error here, just some longer text")
+	mustSave(t, f, path.Join(base, "logs/data.bin"), "error\x00binary")
+
+	tests := []struct {
+		name string
+		opts finder.GrepOptions
+		want string
+	}{
+		{name: "fixed", opts: finder.GrepOptions{Pattern: "error"}, want: "logs/access.log:2,logs/notes.txt:1"},
+		{name: "fixed dot", opts: finder.GrepOptions{Pattern: "a.b"}, want: "logs/access.log:3"},
+		{name: "ignore case", opts: finder.GrepOptions{Pattern: "error", IgnoreCase: true},
+			want: "app.log:2,logs/access.log:2,logs/notes.txt:1"},
+		{name: "regex", opts: finder.GrepOptions{Pattern: `^(start|GET /$)`, Regex: true}, want: "app.log:1,logs/access.log:1"},
+		{name: "include", opts: finder.GrepOptions{Pattern: "error", IgnoreCase: true, Include: "*.log"},
+			want: "app.log:2,logs/access.log:2"},
+		{name: "max file size", opts: finder.GrepOptions{Pattern: "error", MaxFileSize: 30}, want: "logs/access.log:2"},
+		{name: "no match", opts: finder.GrepOptions{Pattern: "panic"}, want: ""},
+	}
+
+	for _, tc := range tests {
+		matches, err := f.Grep(context.Background(), base, tc.opts)
+		if err != nil {
+			t.Fatalf("Grep %s: %v", tc.name, err)
+		}
+
+		got := make([]string, 0, len(matches))
+		for _, m := range matches {
+			got = append(got, fmt.Sprintf("%s:%d", strings.TrimPrefix(m.Path, base+"/"), m.Line))
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("Grep %s = %v, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	matches, err := f.Grep(context.Background(), base, finder.GrepOptions{Pattern: "GET", Limit: 1})
+	if err != nil {
+		t.Fatalf("Grep limit: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Text != "GET /" {
+		t.Errorf("Grep limit = %+v, want one match with text %q", matches, "GET /")
+	}
+
+	for _, opts := range []finder.GrepOptions{{}, {Pattern: "(", Regex: true}} {
+		if _, err = f.Grep(context.Background(), base, opts); err == nil {
+			t.Errorf("Grep %+v should fail", opts)
+		}
+	}
+}
+
+func testSave(t *testing.T, f finder.Finder, base string) {
+	file := path.Join(base, "config.yaml")
+	mustSave(t, f, file, "a long line of content")
+	assertContent(t, f, file, "a long line of content")
+
+	// 覆盖写入必须截断旧内容
+	mustSave(t, f, file, "short")
+	assertContent(t, f, file, "short")
+
+	content, err := f.Preview(context.Background(), file)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if got := readAll(t, content); got != "short" || content.Size != 5 || content.Name != "config.yaml" {
+		t.Errorf("Preview = %q (name %q, size %d), want %q", got, content.Name, content.Size, "short")
+	}
+}
+
+func testPut(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	file := path.Join(base, "deep/nested/put.txt")
+	if err := f.Put(ctx, file, strings.NewReader("streamed content"), -1); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	assertContent(t, f, file, "streamed content")
+
+	if err := f.Put(ctx, file, strings.NewReader("short"), 5); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	assertContent(t, f, file, "short")
+}
+
+func testDownloadSeek(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "range.txt"), "0123456789")
+
+	content, err := f.Download(context.Background(), path.Join(base, "range.txt"))
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if content.Size != 10 || content.ModTime.IsZero() {
+		t.Errorf("Download size = %d, modtime = %v", content.Size, content.ModTime)
+	}
+
+	// 断点续传只读取指定位置之后的内容
+	if _, err = content.Seek(6, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if got := readAll(t, content); got != "6789" {
+		t.Errorf("content after seek = %q, want %q", got, "6789")
+	}
+}
+
+func testUpload(t *testing.T, f finder.Finder, base string) {
+	mustUpload(t, f, base, "upload.bin", "binary\x00content")
+	assertContent(t, f, path.Join(base, "upload.bin"), "binary\x00content")
+}
+
+func testUploadNested(t *testing.T, f finder.Finder, base string) {
+	// 上传文件夹时文件名称会携带相对路径
+	mustUpload(t, f, base, "x/y/z.txt", "nested")
+	assertContent(t, f, path.Join(base, "x/y/z.txt"), "nested")
+
+	folders, err := f.Subfolders(context.Background(), adapter(base), path.Join(base, "x"))
+	if err != nil {
+		t.Fatalf("Subfolders: %v", err)
+	}
+	if got := strings.Join(basenames(folders), ","); got != "y" {
+		t.Errorf("Subfolders = %s, want y", got)
+	}
+}
+
+func testUploadOverwrite(t *testing.T, f finder.Finder, base string) {
+	mustUpload(t, f, base, "dup.txt", "first version")
+	mustUpload(t, f, base, "dup.txt", "second")
+	assertContent(t, f, path.Join(base, "dup.txt"), "second")
+}
+
+func testNewFolder(t *testing.T, f finder.Finder, base string) {
+	mustNewFolder(t, f, base, "dir")
+	// 重复创建目录不报错
+	mustNewFolder(t, f, base, "dir")
+
+	if err := f.NewFile(context.Background(), path.Join(base, "dir"), "empty.txt"); err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+	assertContent(t, f, path.Join(base, "dir/empty.txt"), "")
+}
+
+func testRename(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustSave(t, f, path.Join(base, "long-name.txt"), "file")
+	mustUpload(t, f, base, "folder/inner.txt", "inner")
+
+	// 新名称比旧名称短也必须可以重命名
+	if err := f.Rename(ctx, path.Join(base, "long-name.txt"), "a.txt", base); err != nil {
+		t.Fatalf("Rename file: %v", err)
+	}
+	if err := f.Rename(ctx, path.Join(base, "folder"), "renamed", base); err != nil {
+		t.Fatalf("Rename dir: %v", err)
+	}
+
+	assertNames(t, f, base, "a.txt,renamed")
+	assertContent(t, f, path.Join(base, "a.txt"), "file")
+	assertContent(t, f, path.Join(base, "renamed/inner.txt"), "inner")
+}
+
+func testRenameExisting(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "a.txt"), "a")
+	mustSave(t, f, path.Join(base, "b.txt"), "b")
+
+	if err := f.Rename(context.Background(), path.Join(base, "a.txt"), "b.txt", base); err == nil {
+		t.Error("Rename onto an existing file should fail")
+	}
+
+	assertContent(t, f, path.Join(base, "a.txt"), "a")
+	assertContent(t, f, path.Join(base, "b.txt"), "b")
+}
+
+func testRenameDotEntries(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustNewFolder(t, f, base, "sub")
+	sub := path.Join(base, "sub")
+
+	// . 与 .. 指向当前目录以及上级目录，必须被拒绝
+	_ = f.Rename(ctx, sub, "evil", sub)
+	_ = f.Rename(ctx, base, "evil", sub)
+
+	assertNames(t, f, base, "sub")
+	if _, err := f.Index(ctx, adapter(base), base); err != nil {
+		t.Errorf("base directory was renamed: %v", err)
+	}
+}
+
+func testRenameInvalidName(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustNewFolder(t, f, base, "sub")
+	mustSave(t, f, path.Join(base, "sub/a.txt"), "a")
+
+	// 新名称只能是当前目录下的名称，不能借助分隔符或者 .. 移动到其他目录
+	for _, name := range []string{"", ".", "..", "../a.txt", "inner/a.txt"} {
+		if err := f.Rename(ctx, path.Join(base, "sub/a.txt"), name, path.Join(base, "sub")); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Rename to %q = %v, want %v", name, err, fs.ErrInvalid)
+		}
+	}
+
+	assertNames(t, f, base, "sub")
+	assertContent(t, f, path.Join(base, "sub/a.txt"), "a")
+}
+
+func testMove(t *testing.T, f finder.Finder, base string) {
+	mustNewFolder(t, f, base, "target")
+	mustSave(t, f, path.Join(base, "a.txt"), "a")
+	mustUpload(t, f, base, "dir/b.txt", "b")
+
+	err := f.Move(context.Background(), []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+		{Path: path.Join(base, "dir"), Type: finder.DIR},
+	}, path.Join(base, "target"))
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+
+	assertNames(t, f, base, "target")
+	assertNames(t, f, path.Join(base, "target"), "a.txt,dir")
+	assertContent(t, f, path.Join(base, "target/dir/b.txt"), "b")
+}
+
+func testMoveExisting(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "a.txt"), "new")
+	mustSave(t, f, path.Join(base, "target/a.txt"), "old")
+
+	err := f.Move(context.Background(), []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+	}, path.Join(base, "target"))
+	if err == nil {
+		t.Error("Move onto an existing file should fail")
+	}
+
+	assertContent(t, f, path.Join(base, "a.txt"), "new")
+	assertContent(t, f, path.Join(base, "target/a.txt"), "old")
+}
+
+func testCopy(t *testing.T, f finder.Finder, base string) {
+	mustNewFolder(t, f, base, "target")
+	mustSave(t, f, path.Join(base, "a.txt"), "a")
+	mustUpload(t, f, base, "dir/nested/b.txt", "b")
+
+	err := f.Copy(context.Background(), []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+		{Path: path.Join(base, "dir"), Type: finder.DIR},
+	}, path.Join(base, "target"), "")
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+
+	assertNames(t, f, base, "a.txt,dir,target")
+	assertNames(t, f, path.Join(base, "target"), "a.txt,dir")
+	assertContent(t, f, path.Join(base, "target/a.txt"), "a")
+	assertContent(t, f, path.Join(base, "target/dir/nested/b.txt"), "b")
+	assertContent(t, f, path.Join(base, "dir/nested/b.txt"), "b")
+}
+
+func testCopyConflict(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustSave(t, f, path.Join(base, "a.txt"), "new")
+	mustUpload(t, f, base, "dir/b.txt", "new b")
+	mustSave(t, f, path.Join(base, "target/a.txt"), "old")
+	mustUpload(t, f, base, "target/dir/c.txt", "old c")
+	items := []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+		{Path: path.Join(base, "dir"), Type: finder.DIR},
+	}
+	target := path.Join(base, "target")
+
+	if err := f.Copy(ctx, items, target, ""); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Copy = %v, want %v", err, fs.ErrExist)
+	}
+	assertContent(t, f, path.Join(target, "a.txt"), "old")
+
+	if err := f.Copy(ctx, items, target, finder.ConflictSkip); err != nil {
+		t.Fatalf("Copy skip: %v", err)
+	}
+	assertContent(t, f, path.Join(target, "a.txt"), "old")
+	assertNames(t, f, path.Join(target, "dir"), "c.txt")
+
+	if err := f.Copy(ctx, items, target, finder.ConflictRename); err != nil {
+		t.Fatalf("Copy rename: %v", err)
+	}
+	assertNames(t, f, target, "a-1.txt,a.txt,dir,dir-1")
+	assertContent(t, f, path.Join(target, "a-1.txt"), "new")
+	assertContent(t, f, path.Join(target, "dir-1/b.txt"), "new b")
+
+	// 目录之间覆盖时合并内容
+	if err := f.Copy(ctx, items, target, finder.ConflictOverwrite); err != nil {
+		t.Fatalf("Copy overwrite: %v", err)
+	}
+	assertContent(t, f, path.Join(target, "a.txt"), "new")
+	assertNames(t, f, path.Join(target, "dir"), "b.txt,c.txt")
+
+	// 复制到当前目录即创建副本
+	if err := f.Copy(ctx, items[:1], base, finder.ConflictRename); err != nil {
+		t.Fatalf("Copy duplicate: %v", err)
+	}
+	assertContent(t, f, path.Join(base, "a-1.txt"), "new")
+	if err := f.Copy(ctx, items[:1], base, finder.ConflictOverwrite); err != nil {
+		t.Fatalf("Copy onto itself: %v", err)
+	}
+	assertContent(t, f, path.Join(base, "a.txt"), "new")
+}
+
+func testCopyIntoItself(t *testing.T, f finder.Finder, base string) {
+	mustUpload(t, f, base, "dir/sub/a.txt", "a")
+
+	err := f.Copy(context.Background(), []finder.Item{
+		{Path: path.Join(base, "dir"), Type: finder.DIR},
+	}, path.Join(base, "dir/sub"), "")
+	if err == nil {
+		t.Fatal("Copy a directory into itself should fail")
+	}
+
+	assertNames(t, f, path.Join(base, "dir/sub"), "a.txt")
+}
+
+func testChmod(t *testing.T, f finder.Finder, base string) {
+	// 对象存储等没有权限位的实现需要返回 errors.ErrUnsupported
+	if !finder.CapabilitiesOf(f).Chmod {
+		err := f.Chmod(context.Background(), []finder.Item{{Path: base, Type: finder.DIR}}, "755", false)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Chmod = %v, want %v", err, errors.ErrUnsupported)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	mustSave(t, f, path.Join(base, "a.txt"), "a")
+	mustSave(t, f, path.Join(base, "dir/b.txt"), "b")
+
+	file := []finder.Item{{Path: path.Join(base, "a.txt"), Type: finder.FILE}}
+	for _, tc := range []struct{ mode, want string }{
+		{mode: "640", want: "0640"},
+		{mode: "u+x,g=u", want: "0770"},
+		{mode: "o+r,a-x", want: "0664"},
+		{mode: "+X", want: "0664"},
+	} {
+		if err := f.Chmod(ctx, file, tc.mode, false); err != nil {
+			t.Fatalf("Chmod %s: %v", tc.mode, err)
+		}
+		if got := perm(t, f, base, "a.txt"); got != tc.want {
+			t.Errorf("Chmod %s = %s, want %s", tc.mode, got, tc.want)
+		}
+	}
+
+	dir := []finder.Item{{Path: path.Join(base, "dir"), Type: finder.DIR}}
+	for _, tc := range []struct{ mode, dir, file string }{
+		{mode: "go-rwx", dir: "0700", file: "0600"},
+		{mode: "a+X", dir: "0711", file: "0600"},
+	} {
+		if err := f.Chmod(ctx, dir, tc.mode, true); err != nil {
+			t.Fatalf("Chmod %s: %v", tc.mode, err)
+		}
+		if got := perm(t, f, base, "dir"); got != tc.dir {
+			t.Errorf("Chmod %s dir = %s, want %s", tc.mode, got, tc.dir)
+		}
+		if got := perm(t, f, path.Join(base, "dir"), "b.txt"); got != tc.file {
+			t.Errorf("Chmod %s file = %s, want %s", tc.mode, got, tc.file)
+		}
+	}
+
+	for _, mode := range []string{"", "999", "8", "u+q", "x+r", "g=uo"} {
+		if err := f.Chmod(ctx, file, mode, false); err == nil {
+			t.Errorf("Chmod %q should fail", mode)
+		}
+	}
+}
+
+func perm(t *testing.T, f finder.Finder, dir, name string) string {
+	t.Helper()
+	storage, err := f.Index(context.Background(), adapter(dir), dir)
+	if err != nil {
+		t.Fatalf("Index %s: %v", dir, err)
+	}
+
+	return mustFind(t, storage.Files, name).Perm
+}
+
+func testRemove(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "a.txt"), "a")
+	mustSave(t, f, path.Join(base, "keep.txt"), "keep")
+	mustUpload(t, f, base, "dir/nested/b.txt", "b")
+
+	err := f.Remove(context.Background(), []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+		{Path: path.Join(base, "dir"), Type: finder.DIR},
+	}, base)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	assertNames(t, f, base, "keep.txt")
+}
+
+func testRemoveDotEntries(t *testing.T, f finder.Finder, base string) {
+	mustUpload(t, f, base, "sub/a.txt", "a")
+	sub := path.Join(base, "sub")
+
+	err := f.Remove(context.Background(), []finder.Item{
+		{Path: sub, Type: finder.DIR},
+		{Path: base, Type: finder.DIR},
+	}, sub)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	assertNames(t, f, base, "sub")
+	assertNames(t, f, sub, "a.txt")
+}
+
+func testArchive(t *testing.T, f finder.Finder, base string) {
+	mustSave(t, f, path.Join(base, "a.txt"), "a")
+	mustUpload(t, f, base, "dir/b.txt", "b")
+
+	err := f.Archive(context.Background(), []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+		{Path: path.Join(base, "dir"), Type: finder.DIR},
+	}, "bundle", base, "")
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	assertNames(t, f, base, "a.txt,bundle.zip,dir")
+	entries := readZip(t, f, path.Join(base, "bundle.zip"))
+	want := map[string]string{"a.txt": "a", "dir/": "", "dir/b.txt": "b"}
+	if len(entries) != len(want) {
+		t.Fatalf("zip entries = %v, want %v", entries, want)
+	}
+	for name, content := range want {
+		if got, ok := entries[name]; !ok || got != content {
+			t.Errorf("zip entry %q = %q, want %q", name, got, content)
+		}
+	}
+
+	// 已携带后缀时不再重复添加
+	err = f.Archive(context.Background(), []finder.Item{
+		{Path: path.Join(base, "a.txt"), Type: finder.FILE},
+	}, "single.zip", base, "")
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	assertNames(t, f, base, "a.txt,bundle.zip,dir,single.zip")
+}
+
+func testArchiveDotEntries(t *testing.T, f finder.Finder, base string) {
+	mustUpload(t, f, base, "sub/a.txt", "a")
+	mustSave(t, f, path.Join(base, "secret.txt"), "secret")
+	sub := path.Join(base, "sub")
+
+	err := f.Archive(context.Background(), []finder.Item{
+		{Path: path.Join(sub, "a.txt"), Type: finder.FILE},
+		{Path: base, Type: finder.DIR},
+	}, "bundle", sub, "")
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	entries := readZip(t, f, path.Join(sub, "bundle.zip"))
+	if len(entries) != 1 || entries["a.txt"] != "a" {
+		t.Errorf("zip entries = %v, want only a.txt", entries)
+	}
+}
+
+func testArchiveFormats(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustSave(t, f, path.Join(base, "src/a.txt"), "a")
+	mustUpload(t, f, base, "src/dir/b.txt", "b")
+	src := path.Join(base, "src")
+	items := []finder.Item{
+		{Path: path.Join(src, "a.txt"), Type: finder.FILE},
+		{Path: path.Join(src, "dir"), Type: finder.DIR},
+	}
+
+	for _, format := range []finder.ArchiveFormat{finder.FormatTar, finder.FormatTarGz, finder.FormatTarZst} {
+		if err := f.Archive(ctx, items, "bundle", src, format); err != nil {
+			t.Fatalf("Archive %s: %v", format, err)
+		}
+
+		archive := path.Join(src, "bundle."+string(format))
+		target := path.Join(base, string(format))
+		if err := f.Unarchive(ctx, archive, target, ""); err != nil {
+			t.Fatalf("Unarchive %s: %v", format, err)
+		}
+
+		assertNames(t, f, target, "a.txt,dir")
+		assertContent(t, f, path.Join(target, "a.txt"), "a")
+		assertContent(t, f, path.Join(target, "dir/b.txt"), "b")
+	}
+
+	// 未指定格式时根据名称后缀识别
+	if err := f.Archive(ctx, items[:1], "single.tgz", src, ""); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if err := f.Unarchive(ctx, path.Join(src, "single.tgz"), path.Join(base, "single"), ""); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+	assertContent(t, f, path.Join(base, "single/a.txt"), "a")
+
+	if err := f.Archive(ctx, items, "bundle", src, "rar"); !errors.Is(err, finder.ErrUnknownFormat) {
+		t.Errorf("Archive rar = %v, want %v", err, finder.ErrUnknownFormat)
+	}
+}
+
+func testUnarchive(t *testing.T, f finder.Finder, base string) {
+	mustUpload(t, f, base, "bundle.zip", newZip(t, "a.txt", "a", "dir/", "", "dir/b.txt", "b", "implicit/c.txt", "c"))
+
+	target := path.Join(base, "bundle")
+	if err := f.Unarchive(context.Background(), path.Join(base, "bundle.zip"), target, ""); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+
+	assertNames(t, f, target, "a.txt,dir,implicit")
+	assertContent(t, f, path.Join(target, "a.txt"), "a")
+	assertContent(t, f, path.Join(target, "dir", "b.txt"), "b")
+	assertContent(t, f, path.Join(target, "implicit", "c.txt"), "c")
+}
+
+func testUnarchiveConflict(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	archive := path.Join(base, "bundle.zip")
+	mustUpload(t, f, base, "bundle.zip", newZip(t, "a.txt", "new a", "b.txt", "new b"))
+	mustSave(t, f, path.Join(base, "a.txt"), "old a")
+
+	// 默认策略存在冲突时不写入任何文件
+	err := f.Unarchive(ctx, archive, base, "")
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Unarchive = %v, want %v", err, fs.ErrExist)
+	}
+	assertNames(t, f, base, "a.txt,bundle.zip")
+
+	if err = f.Unarchive(ctx, archive, base, finder.ConflictSkip); err != nil {
+		t.Fatalf("Unarchive skip: %v", err)
+	}
+	assertContent(t, f, path.Join(base, "a.txt"), "old a")
+	assertContent(t, f, path.Join(base, "b.txt"), "new b")
+
+	if err = f.Unarchive(ctx, archive, base, finder.ConflictOverwrite); err != nil {
+		t.Fatalf("Unarchive overwrite: %v", err)
+	}
+	assertContent(t, f, path.Join(base, "a.txt"), "new a")
+}
+
+func testUnarchiveZipSlip(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustNewFolder(t, f, base, "sub")
+	sub := path.Join(base, "sub")
+
+	for _, name := range []string{"../evil.txt", "ok/../../evil.txt", "/evil.txt"} {
+		mustUpload(t, f, sub, "bundle.zip", newZip(t, "safe.txt", "safe", name, "evil"))
+
+		err := f.Unarchive(ctx, path.Join(sub, "bundle.zip"), path.Join(sub, "out"), finder.ConflictOverwrite)
+		if !errors.Is(err, finder.ErrUnsafePath) {
+			t.Errorf("Unarchive %s = %v, want %v", name, err, finder.ErrUnsafePath)
+		}
+	}
+
+	assertNames(t, f, base, "sub")
+	assertNames(t, f, sub, "bundle.zip")
+}
+
+func testUnarchiveTarSlip(t *testing.T, f finder.Finder, base string) {
+	ctx := context.Background()
+	mustNewFolder(t, f, base, "sub")
+	sub := path.Join(base, "sub")
+
+	for _, entries := range [][]string{
+		{"safe.txt", "safe", "../evil.txt", "evil"},
+		{"safe.txt", "safe", "link -> ../../evil", ""},
+		{"safe.txt", "safe", "link -> /etc", ""},
+		// 软链接指向解压目录内部，但不允许通过软链接写入文件
+		{"dir/", "", "link -> dir", "", "link/evil.txt", "evil"},
+	} {
+		mustUpload(t, f, sub, "bundle.tar", newTar(t, entries...))
+
+		err := f.Unarchive(ctx, path.Join(sub, "bundle.tar"), path.Join(sub, "out"), finder.ConflictOverwrite)
+		if !errors.Is(err, finder.ErrUnsafePath) {
+			t.Errorf("Unarchive %v = %v, want %v", entries, err, finder.ErrUnsafePath)
+		}
+	}
+
+	assertNames(t, f, base, "sub")
+	assertNames(t, f, sub, "bundle.tar")
+}
+
+// adapter 工作目录对应的存储名称
+func adapter(base string) string {
+	return strings.Split(strings.TrimPrefix(base, "/"), "/")[0]
+}
+
+func withoutDots(files []finder.FileInfo) []finder.FileInfo {
+	res := make([]finder.FileInfo, 0, len(files))
+	for _, file := range files {
+		if file.Basename == "." || file.Basename == ".." {
+			continue
+		}
+		res = append(res, file)
+	}
+	return res
+}
+
+// basenames 返回排序后的文件名称，不同实现返回的顺序不保证一致
+func basenames(files []finder.FileInfo) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Basename)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mustFind(t *testing.T, files []finder.FileInfo, name string) finder.FileInfo {
+	t.Helper()
+	for _, file := range files {
+		if file.Basename == name {
+			return file
+		}
+	}
+
+	t.Fatalf("%s not found in %v", name, basenames(files))
+	return finder.FileInfo{}
+}
+
+func mustSave(t *testing.T, f finder.Finder, file, content string) {
+	t.Helper()
+	if err := f.NewFolder(context.Background(), path.Dir(file), ""); err != nil {
+		t.Fatalf("NewFolder: %v", err)
+	}
+	if err := f.Save(context.Background(), file, content); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func mustNewFolder(t *testing.T, f finder.Finder, dir, name string) {
+	t.Helper()
+	if err := f.NewFolder(context.Background(), dir, name); err != nil {
+		t.Fatalf("NewFolder: %v", err)
+	}
+}
+
+func mustUpload(t *testing.T, f finder.Finder, dir, name, content string) {
+	t.Helper()
+	if err := f.Upload(context.Background(), newFileHeader(t, name, content), dir, name); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+}
+
+// newFileHeader 构造与 gin 解析表单后一致的上传文件
+func newFileHeader(t *testing.T, name, content string) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", path.Base(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.WriteString(part, content); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func assertContent(t *testing.T, f finder.Finder, file, want string) {
+	t.Helper()
+	content, err := f.Download(context.Background(), file)
+	if err != nil {
+		t.Fatalf("Download %s: %v", file, err)
+	}
+	if got := readAll(t, content); got != want {
+		t.Errorf("%s = %q, want %q", file, got, want)
+	}
+}
+
+func assertNames(t *testing.T, f finder.Finder, dir, want string) {
+	t.Helper()
+	storage, err := f.Index(context.Background(), adapter(dir), dir)
+	if err != nil {
+		t.Fatalf("Index %s: %v", dir, err)
+	}
+	if got := strings.Join(basenames(withoutDots(storage.Files)), ","); got != want {
+		t.Errorf("%s contains %s, want %s", dir, got, want)
+	}
+}
+
+func readZip(t *testing.T, f finder.Finder, file string) map[string]string {
+	t.Helper()
+	content, err := f.Download(context.Background(), file)
+	if err != nil {
+		t.Fatalf("Download %s: %v", file, err)
+	}
+
+	data := readAll(t, content)
+	reader, err := zip.NewReader(strings.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+
+	entries := make(map[string]string, len(reader.File))
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name] = string(content)
+	}
+	return entries
+}
+
+// newZip 按照 名称, 内容 的顺序构造压缩包，名称以 / 结尾表示目录
+func newZip(t *testing.T, entries ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		w, err := zipWriter.Create(entries[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(w, entries[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// newTar 与 newZip 格式相同，名称形如 link -> target 时表示软链接
+func newTar(t *testing.T, entries ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		header := &tar.Header{Name: entries[i], Mode: 0644, Size: int64(len(entries[i+1])), Typeflag: tar.TypeReg}
+		if name, link, ok := strings.Cut(entries[i], " -> "); ok {
+			header = &tar.Header{Name: name, Linkname: link, Mode: 0777, Typeflag: tar.TypeSymlink}
+		} else if strings.HasSuffix(entries[i], "/") {
+			header = &tar.Header{Name: entries[i], Mode: 0755, Typeflag: tar.TypeDir}
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tarWriter, entries[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// readAll 读取并关闭文件内容
+func readAll(t *testing.T, content finder.Content) string {
+	t.Helper()
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatalf("read content: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,240 @@
+package finder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultGrepFileSize 未指定大小时跳过超过 10MB 的文件
+	defaultGrepFileSize = 10 << 20
+	// maxGrepFiles 逐个读取时最多扫描的文件数量
+	maxGrepFiles = 10000
+	// maxGrepLine 单行的最大长度，超过时跳过该文件
+	maxGrepLine = 1 << 20
+	// maxSnippet 返回的匹配行最多保留的字节数
+	maxSnippet = 512
+)
+
+// GrepOptions 内容搜索条件
+type GrepOptions struct {
+	Pattern string
+	// Regex 为 false 时按照固定字符串匹配
+	Regex      bool
+	IgnoreCase bool
+	// Include 只搜索名称匹配的文件，shell 通配符，例如 *.log
+	Include string
+	// MaxFileSize 跳过超过大小的文件，默认 10MB
+	MaxFileSize int64
+	// Limit 最多返回的匹配行数，默认 1000
+	Limit int
+}
+
+// GrepMatch 匹配的行
+type GrepMatch struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+func (o GrepOptions) maxFileSize() int64 {
+	if o.MaxFileSize <= 0 {
+		return defaultGrepFileSize
+	}
+
+	return o.MaxFileSize
+}
+
+func (o GrepOptions) limit() int {
+	if o.Limit <= 0 {
+		return defaultSearchLimit
+	}
+
+	return o.Limit
+}
+
+func (o GrepOptions) matcher() (func(line string) bool, error) {
+	if o.Pattern == "" {
+		return nil, errors.New("grep pattern is required")
+	}
+
+	if o.Regex {
+		pattern := o.Pattern
+		if o.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", o.Pattern, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if o.IgnoreCase {
+		pattern := strings.ToLower(o.Pattern)
+		return func(line string) bool {
+			return strings.Contains(strings.ToLower(line), pattern)
+		}, nil
+	}
+
+	return func(line string) bool {
+		return strings.Contains(line, o.Pattern)
+	}, nil
+}
+
+// grepFiles 列出 root 下的文件后逐个读取查找匹配的行，适用于无法在服务端执行命令的存储
+func grepFiles(ctx context.Context, root string, opts GrepOptions,
+	scan func(dir string) ([]FileInfo, error), open func(path string) (Content, error)) ([]GrepMatch, error) {
+	match, err := opts.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := scan(root)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := SearchOptions{Type: FILE, MaxSize: opts.maxFileSize(), Limit: maxGrepFiles}
+	if opts.Include != "" {
+		filter.Pattern, filter.Match = opts.Include, MatchGlob
+	}
+
+	files, err = search(ctx, files, filter, scan)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]GrepMatch, 0)
+	for _, file := range files {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if matches, err = grepFile(file.Path, match, opts.limit(), matches, open); err != nil {
+			slog.Warn("内容搜索时跳过无法读取的文件", slog.String("path", file.Path), slog.Any("err", err))
+		}
+		if len(matches) >= opts.limit() {
+			break
+		}
+	}
+
+	return matches, nil
+}
+
+// grepFile 与 grep -I 一致，跳过包含 NUL 字符的二进制文件
+func grepFile(path string, match func(string) bool, limit int, matches []GrepMatch,
+	open func(path string) (Content, error)) ([]GrepMatch, error) {
+	file, err := open(path)
+	if err != nil {
+		return matches, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(512)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return matches, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxGrepLine)
+	for line := 1; scanner.Scan(); line++ {
+		if text := scanner.Text(); match(text) {
+			matches = append(matches, GrepMatch{Path: path, Line: line, Text: snippet(text)})
+			if len(matches) >= limit {
+				return matches, nil
+			}
+		}
+	}
+
+	return matches, scanner.Err()
+}
+
+// snippet 截断过长的行，保证不会截断在多字节字符中间
+func snippet(line string) string {
+	if len(line) <= maxSnippet {
+		return line
+	}
+
+	end := maxSnippet
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+
+	return line[:end]
+}
+
+// grepDone 远程命令结束时输出的标记，匹配的行总是包含 NUL 字符，不会与标记混淆
+const grepDone = "vuefinder-grep-done"
+
+// grepCommand find 按照名称以及大小筛选文件后交给 grep，-I 跳过二进制文件
+// --null 在文件名后输出 NUL 字符，避免文件名中的冒号影响解析
+func grepCommand(root string, opts GrepOptions) string {
+	// 以 - 开头的起始路径会被 find 当作表达式
+	if !strings.HasPrefix(root, "/") {
+		root = "./" + root
+	}
+
+	var cmd strings.Builder
+	cmd.WriteString("find " + shellQuote(root) + " -type f")
+	if opts.Include != "" {
+		cmd.WriteString(" -name " + shellQuote(opts.Include))
+	}
+
+	fmt.Fprintf(&cmd, " -size -%dc -print0 | xargs -0 -r grep -n -H -I --null", opts.maxFileSize()+1)
+	if opts.IgnoreCase {
+		cmd.WriteString(" -i")
+	}
+	if opts.Regex {
+		cmd.WriteString(" -E")
+	} else {
+		cmd.WriteString(" -F")
+	}
+
+	cmd.WriteString(" -e " + shellQuote(opts.Pattern) + "; echo " + grepDone)
+	return cmd.String()
+}
+
+// parseGrep 解析 grep -n --null 的输出，格式为 文件名\0行号:内容，done 表示读取到了结束标记
+func parseGrep(r io.Reader, limit int) ([]GrepMatch, bool, error) {
+	matches := make([]GrepMatch, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxGrepLine)
+	for scanner.Scan() {
+		if scanner.Text() == grepDone {
+			return matches, true, nil
+		}
+
+		path, rest, ok := strings.Cut(scanner.Text(), "\x00")
+		if !ok {
+			continue
+		}
+
+		lineNo, text, ok := strings.Cut(rest, ":")
+		if !ok {
+			continue
+		}
+
+		line, err := strconv.Atoi(lineNo)
+		if err != nil {
+			continue
+		}
+
+		matches = append(matches, GrepMatch{Path: path, Line: line, Text: snippet(text)})
+		if len(matches) >= limit {
+			return matches, false, nil
+		}
+	}
+
+	return matches, false, scanner.Err()
+}
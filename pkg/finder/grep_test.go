@@ -0,0 +1,29 @@
+package finder
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestGrepCommandDashRoot 以 - 开头的起始路径不能被 find 当作表达式，例如 -delete 会删除工作目录下的文件
+func TestGrepCommandDashRoot(t *testing.T) {
+	if _, err := exec.LookPath("find"); err != nil {
+		t.Skip(err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "keep.txt")
+	if err := os.WriteFile(file, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sh", "-c", grepCommand("-delete", GrepOptions{Pattern: "keep"}))
+	cmd.Dir = dir
+	out, _ := cmd.CombinedOutput()
+
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("grep with root -delete removed files: %v, output %s", err, out)
+	}
+}
@@ -0,0 +1,704 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrOutsideRoot 路径通过 .. 或者软链接超出了根目录，可以通过 errors.Is(err, fs.ErrPermission) 判断
+var ErrOutsideRoot = fmt.Errorf("path is outside of the root: %w", fs.ErrPermission)
+
+// maxLinks 解析路径时最多跟随的软链接数量，避免链接循环
+const maxLinks = 255
+
+// realPather 解析路径中所有的软链接，返回真实路径，不存在的部分按照字面拼接
+// 没有软链接的存储不需要实现，只做字面检查
+type realPather interface {
+	realPath(path string) (string, error)
+}
+
+// linkReader evalSymlinks 逐级读取路径使用
+type linkReader interface {
+	Lstat(path string) (fs.FileInfo, error)
+	ReadLink(path string) (string, error)
+}
+
+type jailFinder struct {
+	inner Finder
+	// root inner 中的根目录，客户端看到的 / 对应该目录
+	root string
+
+	mu sync.Mutex
+	// realRoot root 本身也可能是软链接，解析后的真实路径，用于判断软链接是否指向根目录以外
+	realRoot string
+}
+
+// NewJailFinder 将 inner 的所有操作限制在 root 目录下，客户端传入以及返回的路径都以 root 为 /
+// 路径中的 .. 超出根目录，或者经过软链接指向根目录以外时返回 ErrOutsideRoot
+// 软链接在操作前检查，检查与操作之间其他进程修改链接的情况无法避免
+func NewJailFinder(inner Finder, root string) Finder {
+	return &jailFinder{
+		inner: inner,
+		root:  path.Clean("/" + root),
+	}
+}
+
+func (j *jailFinder) Index(ctx context.Context, adapter, p string) (Storages, error) {
+	storages, err := j.storages(ctx)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	// 第一次请求没有工作目录的概念，默认进入第一个存储目录
+	dir := "/"
+	if adapter != "null" {
+		dir = getPath(adapter, p)
+	} else if len(storages) > 0 {
+		dir = "/" + storages[0]
+	}
+
+	host, err := j.resolve("index", dir, true)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	storage, err := j.inner.Index(ctx, storageName(dir), host)
+	if err != nil {
+		return Storages{}, j.error(err)
+	}
+
+	files, _ := j.files(storage.Files)
+	return Storages{
+		Adapter:  getFirstPathPart(dir),
+		Storages: storages,
+		Dirname:  path.Clean(dir),
+		Files:    j.dotEntries(path.Clean(dir), files),
+	}, nil
+}
+
+func (j *jailFinder) Subfolders(ctx context.Context, adapter, p string) ([]FileInfo, error) {
+	if strings.Contains(p, "://") {
+		split := strings.Split(p, "://")
+		if split[1] == "" {
+			p = "/" + adapter
+		} else {
+			p = split[1]
+		}
+	}
+
+	host, err := j.resolve("subfolders", p, true)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := j.inner.Subfolders(ctx, adapter, host)
+	if err != nil {
+		return nil, j.error(err)
+	}
+
+	folders, _ = j.files(folders)
+	return folders, nil
+}
+
+func (j *jailFinder) Search(ctx context.Context, adapter, p string, opts SearchOptions) (Storages, error) {
+	dir := getPath(adapter, p)
+	host, err := j.resolve("search", dir, true)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	storage, err := j.inner.Search(ctx, storageName(dir), host, opts)
+	if err != nil {
+		return Storages{}, j.error(err)
+	}
+
+	storages, err := j.storages(ctx)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	// 搜索会进入指向目录的软链接，丢弃经过指向根目录以外的链接找到的文件
+	files, escaped := j.files(storage.Files)
+	files = filterFiles(files, func(file FileInfo) bool {
+		for _, link := range escaped {
+			if within(link, file.Path) && file.Path != link {
+				return false
+			}
+		}
+		return true
+	})
+
+	return Storages{
+		Adapter:  getFirstPathPart(dir),
+		Storages: storages,
+		Dirname:  path.Clean(dir),
+		Files:    files,
+	}, nil
+}
+
+func (j *jailFinder) Grep(ctx context.Context, p string, opts GrepOptions) ([]GrepMatch, error) {
+	host, err := j.resolve("grep", p, true)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := j.inner.Grep(ctx, host, opts)
+	if err != nil {
+		return nil, j.error(err)
+	}
+
+	// 匹配的文件可能位于指向根目录以外的软链接下，同一个文件只检查一次
+	inside := make(map[string]bool)
+	result := make([]GrepMatch, 0, len(matches))
+	for _, match := range matches {
+		ok, checked := inside[match.Path]
+		if !checked {
+			_, err = j.confine(match.Path)
+			ok = err == nil
+			inside[match.Path] = ok
+		}
+
+		if ok {
+			match.Path = j.virtual(match.Path)
+			result = append(result, match)
+		}
+	}
+
+	return result, nil
+}
+
+func (j *jailFinder) Download(ctx context.Context, filePath string) (Content, error) {
+	host, err := j.resolve("download", filePath, true)
+	if err != nil {
+		return Content{}, err
+	}
+
+	content, err := j.inner.Download(ctx, host)
+	return content, j.error(err)
+}
+
+func (j *jailFinder) Preview(ctx context.Context, p string) (Content, error) {
+	host, err := j.resolve("preview", p, true)
+	if err != nil {
+		return Content{}, err
+	}
+
+	content, err := j.inner.Preview(ctx, host)
+	return content, j.error(err)
+}
+
+func (j *jailFinder) Save(ctx context.Context, p, content string) error {
+	host, err := j.resolve("save", p, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Save(ctx, host, content))
+}
+
+func (j *jailFinder) Put(ctx context.Context, p string, content io.Reader, size int64) error {
+	host, err := j.resolve("put", p, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Put(ctx, host, content, size))
+}
+
+// Upload remoteFile 可以包含子目录，与 remoteDir 拼接后整体检查
+func (j *jailFinder) Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error {
+	if _, err := j.resolve("upload", remoteDir+"/"+remoteFile, true); err != nil {
+		return err
+	}
+
+	host, err := j.resolve("upload", remoteDir, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Upload(ctx, src, host, remoteFile))
+}
+
+// Rename 重命名的是软链接本身，不需要跟随最后一级
+func (j *jailFinder) Rename(ctx context.Context, oldPathName, newName, p string) error {
+	host, err := j.resolve("rename", oldPathName, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err = j.resolve("rename", path.Dir(oldPathName)+"/"+newName, false); err != nil {
+		return err
+	}
+
+	current, err := j.host("rename", p)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Rename(ctx, host, newName, current))
+}
+
+func (j *jailFinder) NewFolder(ctx context.Context, file, name string) error {
+	if _, err := j.resolve("mkdir", file+"/"+name, true); err != nil {
+		return err
+	}
+
+	host, err := j.resolve("mkdir", file, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.NewFolder(ctx, host, name))
+}
+
+func (j *jailFinder) NewFile(ctx context.Context, file, name string) error {
+	if _, err := j.resolve("create", file+"/"+name, true); err != nil {
+		return err
+	}
+
+	host, err := j.resolve("create", file, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.NewFile(ctx, host, name))
+}
+
+// Remove 删除软链接时只删除链接本身，不需要跟随最后一级
+func (j *jailFinder) Remove(ctx context.Context, items []Item, p string) error {
+	hostItems, err := j.items("remove", items, false)
+	if err != nil {
+		return err
+	}
+
+	current, err := j.host("remove", p)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Remove(ctx, hostItems, current))
+}
+
+func (j *jailFinder) RemoveDir(ctx context.Context, file string) error {
+	host, err := j.resolve("remove", file, false)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.RemoveDir(ctx, host))
+}
+
+func (j *jailFinder) RemoveFile(ctx context.Context, file string) error {
+	host, err := j.resolve("remove", file, false)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.RemoveFile(ctx, host))
+}
+
+// Archive zip 等格式会读取软链接指向的内容，压缩前检查目录下所有的软链接
+func (j *jailFinder) Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error {
+	hostItems, err := j.items("archive", items, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err = j.resolve("archive", base+"/"+target, true); err != nil {
+		return err
+	}
+
+	hostBase, err := j.resolve("archive", base, true)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range hostItems {
+		if item.Type == DIR {
+			if err = j.confineTree(ctx, "archive", item.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return j.error(j.inner.Archive(ctx, hostItems, target, hostBase, format))
+}
+
+func (j *jailFinder) Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error {
+	hostItem, err := j.resolve("unarchive", item, true)
+	if err != nil {
+		return err
+	}
+
+	hostTarget, err := j.resolve("unarchive", target, true)
+	if err != nil {
+		return err
+	}
+
+	// 解压目录下已有的软链接可能指向根目录以外，压缩包中的条目会经过链接写入，解压目录不存在时无需检查
+	if err = j.confineTree(ctx, "unarchive", hostTarget); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return j.error(j.inner.Unarchive(ctx, hostItem, hostTarget, policy))
+}
+
+func (j *jailFinder) Move(ctx context.Context, items []Item, target string) error {
+	hostItems, err := j.items("move", items, false)
+	if err != nil {
+		return err
+	}
+
+	hostTarget, err := j.resolve("move", target, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Move(ctx, hostItems, hostTarget))
+}
+
+// Copy 同一个存储上复制时软链接按照链接本身复制，不会读取链接指向的内容
+func (j *jailFinder) Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error {
+	hostItems, err := j.items("copy", items, true)
+	if err != nil {
+		return err
+	}
+
+	hostTarget, err := j.resolve("copy", target, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Copy(ctx, hostItems, hostTarget, policy))
+}
+
+// Chmod 递归修改时会跳过软链接，只需要检查 items 本身
+func (j *jailFinder) Chmod(ctx context.Context, items []Item, mode string, recursive bool) error {
+	hostItems, err := j.items("chmod", items, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Chmod(ctx, hostItems, mode, recursive))
+}
+
+func (j *jailFinder) Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error {
+	hostItems, err := j.items("chown", items, true)
+	if err != nil {
+		return err
+	}
+
+	return j.error(j.inner.Chown(ctx, hostItems, owner, group, recursive))
+}
+
+func (j *jailFinder) Capabilities() Capabilities {
+	return CapabilitiesOf(j.inner)
+}
+
+// host 将客户端传入的路径转换为 inner 中的路径，.. 超出根目录时返回 ErrOutsideRoot，不检查软链接
+func (j *jailFinder) host(op, p string) (string, error) {
+	depth := 0
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if depth--; depth < 0 {
+				return "", pathError(op, p, ErrOutsideRoot)
+			}
+		default:
+			depth++
+		}
+	}
+
+	return path.Join(j.root, path.Clean("/"+p)), nil
+}
+
+// resolve follow 为 false 时操作的是软链接本身，只检查上级目录
+func (j *jailFinder) resolve(op, p string, follow bool) (string, error) {
+	host, err := j.host(op, p)
+	if err != nil {
+		return "", err
+	}
+
+	// 根目录本身不允许删除、重命名或者移动
+	check := host
+	if !follow {
+		if host == j.root {
+			return "", pathError(op, p, ErrOutsideRoot)
+		}
+		check = path.Dir(host)
+	}
+
+	if _, err = j.confine(check); errors.Is(err, ErrOutsideRoot) {
+		return "", pathError(op, p, ErrOutsideRoot)
+	}
+
+	return host, j.error(err)
+}
+
+func (j *jailFinder) items(op string, items []Item, follow bool) ([]Item, error) {
+	hostItems := make([]Item, 0, len(items))
+	for _, item := range items {
+		host, err := j.resolve(op, item.Path, follow)
+		if err != nil {
+			return nil, err
+		}
+		hostItems = append(hostItems, Item{Path: host, Type: item.Type})
+	}
+
+	return hostItems, nil
+}
+
+// realPath 实现 realPather，返回客户端看到的真实路径，嵌套的 jailFinder 通过该方法检查软链接
+func (j *jailFinder) realPath(p string) (string, error) {
+	host, err := j.host("realpath", p)
+	if err != nil {
+		return "", err
+	}
+
+	real, err := j.confine(host)
+	if err != nil {
+		return "", err
+	}
+
+	return j.virtual(real), nil
+}
+
+// confine 解析 inner 中路径 host 的软链接，真实路径不在根目录下时返回 ErrOutsideRoot
+// 存储不支持软链接时只做字面检查
+func (j *jailFinder) confine(host string) (string, error) {
+	rp, ok := j.inner.(realPather)
+	if !ok {
+		if !within(j.root, host) {
+			return "", pathError("realpath", host, ErrOutsideRoot)
+		}
+		return host, nil
+	}
+
+	root, err := j.resolveRoot(rp)
+	if err != nil {
+		return "", err
+	}
+
+	real, err := rp.realPath(host)
+	if err != nil {
+		return "", err
+	}
+
+	if !within(root, real) {
+		return "", pathError("realpath", host, ErrOutsideRoot)
+	}
+
+	return real, nil
+}
+
+// confineTree 检查目录下的所有软链接，搜索会跟随软链接，链接的下级不需要单独检查
+func (j *jailFinder) confineTree(ctx context.Context, op, host string) error {
+	if _, ok := j.inner.(realPather); !ok {
+		return nil
+	}
+
+	storage, err := j.inner.Search(ctx, storageName(j.virtual(host)), host, SearchOptions{Limit: math.MaxInt})
+	if err != nil {
+		return j.error(err)
+	}
+
+	for _, file := range storage.Files {
+		if file.LinkTarget == "" {
+			continue
+		}
+
+		if _, err = j.confine(file.Path); errors.Is(err, ErrOutsideRoot) {
+			return pathError(op, j.virtual(file.Path), ErrOutsideRoot)
+		}
+	}
+
+	return nil
+}
+
+func (j *jailFinder) resolveRoot(rp realPather) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.realRoot == "" {
+		root, err := rp.realPath(j.root)
+		if err != nil {
+			return "", err
+		}
+		j.realRoot = root
+	}
+
+	return j.realRoot, nil
+}
+
+// virtual 将 inner 中的路径转换为客户端看到的路径，根目录的上级目录视为根目录
+func (j *jailFinder) virtual(host string) string {
+	host = path.Clean(host)
+	for _, root := range []string{j.root, j.realRoot} {
+		switch {
+		case root == "":
+		case root == "/":
+			return host
+		case host == root:
+			return "/"
+		case strings.HasPrefix(host, root+"/"):
+			return strings.TrimPrefix(host, root)
+		}
+	}
+
+	return "/"
+}
+
+// files 转换文件的路径，软链接的目标转换为解析后的路径，指向根目录以外的软链接标记为失效并返回这些链接的路径
+func (j *jailFinder) files(files []FileInfo) ([]FileInfo, []string) {
+	var escaped []string
+	result := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if file.Basename == "." || file.Basename == ".." {
+			continue
+		}
+
+		host := file.Path
+		file.Path = j.virtual(host)
+		file.Storage = storageName(file.Path)
+
+		if file.LinkTarget != "" {
+			target := file.LinkTarget
+			if !path.IsAbs(target) {
+				target = path.Join(path.Dir(host), target)
+			}
+
+			// 失效的链接无法解析，按照字面判断
+			real, err := j.confine(host)
+			switch {
+			case err == nil:
+				file.LinkTarget = j.virtual(real)
+			case !errors.Is(err, ErrOutsideRoot) && within(j.root, target):
+				file.LinkTarget = j.virtual(target)
+			default:
+				escaped = append(escaped, file.Path)
+				file.LinkTarget, file.BrokenLink = "", true
+			}
+		}
+
+		result = append(result, file)
+	}
+
+	return result, escaped
+}
+
+// dotEntries 根目录的下级不返回 . 以及 ..，与其他实现保持一致
+func (j *jailFinder) dotEntries(dir string, files []FileInfo) []FileInfo {
+	if !matchPath(dir) {
+		return files
+	}
+
+	return append([]FileInfo{
+		{Basename: ".", Type: DIR, Path: dir},
+		{Basename: "..", Type: DIR, Path: path.Dir(dir)},
+	}, files...)
+}
+
+// storages 根目录下的目录作为存储
+func (j *jailFinder) storages(ctx context.Context) ([]string, error) {
+	folders, err := j.inner.Subfolders(ctx, "", j.root)
+	if err != nil {
+		return nil, j.error(err)
+	}
+
+	storages := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		if folder.LinkTarget == "" {
+			storages = append(storages, folder.Basename)
+		}
+	}
+
+	return storages, nil
+}
+
+// error 错误信息中的路径同样转换为客户端看到的路径，避免暴露根目录
+func (j *jailFinder) error(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pathError(pe.Op, j.virtual(pe.Path), pe.Err)
+	}
+
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return &os.LinkError{Op: le.Op, Old: j.virtual(le.Old), New: j.virtual(le.New), Err: le.Err}
+	}
+
+	return err
+}
+
+// storageName 路径的第一级目录，根目录为空
+func storageName(p string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(path.Clean(p), "/"), "/")
+	return name
+}
+
+func filterFiles(files []FileInfo, keep func(file FileInfo) bool) []FileInfo {
+	result := files[:0]
+	for _, file := range files {
+		if keep(file) {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+// evalSymlinks 逐级解析绝对路径 p 中的软链接，不存在的部分按照字面拼接
+func evalSymlinks(links linkReader, p string) (string, error) {
+	resolved, hops := "/", 0
+	rest := strings.Split(path.Clean("/"+p), "/")
+	for len(rest) > 0 {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		info, err := links.Lstat(next)
+		if errors.Is(err, fs.ErrNotExist) {
+			return path.Join(append([]string{next}, rest...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if hops++; hops > maxLinks {
+			return "", pathError("realpath", p, errors.New("too many links"))
+		}
+
+		target, err := links.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,505 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"github.com/ecodeclub/ekit/slice"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type localFinder struct {
+	// root 本地根目录，所有路径都基于该目录解析
+	root string
+	// accounts 本机的用户以及用户组名称，不受 root 限制
+	accounts accountCache
+}
+
+// NewLocalFinder 基于本机文件系统的 Finder，root 为对外暴露的根目录
+func NewLocalFinder(root string) Finder {
+	return &localFinder{
+		root: filepath.Clean(root),
+	}
+}
+
+// abs 将前端传入的路径转换为本地绝对路径，禁止越过根目录
+func (lf *localFinder) abs(path string) string {
+	return filepath.Join(lf.root, filepath.Clean("/"+path))
+}
+
+func (lf *localFinder) Save(ctx context.Context, path, content string) error {
+	// 使用 os.O_WRONLY|os.O_TRUNC 来覆盖文件内容
+	file, err := os.OpenFile(lf.abs(path), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// 写入内容到文件
+	_, err = file.WriteString(content)
+	return err
+}
+
+func (lf *localFinder) Put(ctx context.Context, path string, content io.Reader, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(lf.abs(path)), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(lf.abs(path))
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, content); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (lf *localFinder) Subfolders(ctx context.Context, adapter, path string) ([]FileInfo, error) {
+	if strings.Contains(path, "://") {
+		split := strings.Split(path, "://")
+		if split[1] == "" {
+			path = fmt.Sprintf("/%s", adapter)
+		} else {
+			path = split[1]
+		}
+	}
+
+	files, err := lf.scan(path, adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	files = slice.FilterMap(files, func(idx int, src FileInfo) (FileInfo, bool) {
+		if src.Type != DIR {
+			return src, false
+		}
+
+		return src, true
+	})
+
+	return files, nil
+}
+
+func (lf *localFinder) Preview(ctx context.Context, path string) (Content, error) {
+	return lf.open(path)
+}
+
+// Search 从当前目录开始逐层搜索，当前目录的列表复用 Index 的结果
+func (lf *localFinder) Search(ctx context.Context, adapter, path string, opts SearchOptions) (Storages, error) {
+	storage, err := lf.Index(ctx, adapter, path)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	storage.Files, err = search(ctx, storage.Files, opts, func(dir string) ([]FileInfo, error) {
+		return lf.scan(dir, storage.Adapter)
+	})
+	if err != nil {
+		return Storages{}, err
+	}
+
+	return storage, nil
+}
+
+// Grep 逐个读取文件查找匹配的行
+func (lf *localFinder) Grep(ctx context.Context, path string, opts GrepOptions) ([]GrepMatch, error) {
+	scan := func(dir string) ([]FileInfo, error) {
+		return lf.scan(dir, "")
+	}
+
+	return grepFiles(ctx, path, opts, scan, lf.open)
+}
+
+func (lf *localFinder) Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error {
+	// 判断是否有后缀，如果没有自行添加上
+	name, format, err := archiveName(filepath.Join(base, target), format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(lf.abs(name))
+	if err != nil {
+		return err
+	}
+
+	if err = pack(ctx, localSource{lf: lf}, items, base, file, format); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// realPath 软链接中的绝对路径是本机路径，在本机上解析后再转换为相对 root 的路径
+func (lf *localFinder) realPath(path string) (string, error) {
+	root, err := evalSymlinks(osLinks{}, filepath.ToSlash(lf.root))
+	if err != nil {
+		return "", err
+	}
+
+	real, err := evalSymlinks(osLinks{}, filepath.ToSlash(lf.abs(path)))
+	if err != nil {
+		return "", err
+	}
+
+	if !within(root, real) {
+		return "", pathError("realpath", path, ErrOutsideRoot)
+	}
+
+	return filepath.ToSlash(filepath.Join("/", strings.TrimPrefix(real, root))), nil
+}
+
+// osLinks 直接读取本机路径，不经过 root 转换
+type osLinks struct{}
+
+func (osLinks) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (osLinks) ReadLink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+type localSource struct {
+	lf *localFinder
+}
+
+func (s localSource) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(s.lf.abs(path))
+}
+
+func (s localSource) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(s.lf.abs(path))
+}
+
+func (s localSource) ReadLink(path string) (string, error) {
+	return os.Readlink(s.lf.abs(path))
+}
+
+func (s localSource) ReadDir(path string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(s.lf.abs(path))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (s localSource) Open(path string) (io.ReadCloser, error) {
+	return os.Open(s.lf.abs(path))
+}
+
+func (lf *localFinder) Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error {
+	content, err := lf.open(item)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return unarchive(ctx, content, target, policy, localTarget{lf: lf})
+}
+
+type localTarget struct {
+	lf *localFinder
+}
+
+func (t localTarget) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(t.lf.abs(path))
+}
+
+func (t localTarget) MkdirAll(path string) error {
+	return os.MkdirAll(t.lf.abs(path), 0755)
+}
+
+func (t localTarget) Create(path string) (io.WriteCloser, error) {
+	return os.Create(t.lf.abs(path))
+}
+
+func (t localTarget) RemoveAll(path string) error {
+	return os.RemoveAll(t.lf.abs(path))
+}
+
+func (t localTarget) Symlink(link, path string) error {
+	return os.Symlink(link, t.lf.abs(path))
+}
+
+func (t localTarget) Chmod(path string, mode fs.FileMode) error {
+	return os.Chmod(t.lf.abs(path), mode)
+}
+
+func (lf *localFinder) Move(ctx context.Context, items []Item, target string) error {
+	for _, item := range items {
+		destPath := filepath.Join(target, filepath.Base(item.Path))
+
+		err := lf.rename(item.Path, destPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (lf *localFinder) Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error {
+	src, dst := localSource{lf: lf}, localTarget{lf: lf}
+	return copyItems(ctx, src, dst, items, target, policy, func(from, to string, info fs.FileInfo, merge bool) error {
+		return copyTree(ctx, src, dst, from, to, info, merge)
+	})
+}
+
+func (lf *localFinder) Chmod(ctx context.Context, items []Item, mode string, recursive bool) error {
+	return chmodTree(ctx, localSource{lf: lf}, items, mode, recursive, func(p string, mode fs.FileMode) error {
+		return os.Chmod(lf.abs(p), mode)
+	})
+}
+
+func (lf *localFinder) Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error {
+	names := lf.accounts.get(func(name string) (io.ReadCloser, error) {
+		return os.Open(name)
+	})
+
+	return chownTree(ctx, localSource{lf: lf}, items, owner, group, recursive, names, func(p string, uid, gid int) error {
+		return os.Chown(lf.abs(p), uid, gid)
+	})
+}
+
+func (lf *localFinder) Remove(ctx context.Context, items []Item, path string) error {
+	for _, item := range items {
+		if blockOperation("remove", path, item.Path) {
+			continue
+		}
+
+		switch item.Type {
+		case DIR:
+			err := lf.RemoveDir(ctx, item.Path)
+			if err != nil {
+				return err
+			}
+		case FILE:
+			err := lf.RemoveFile(ctx, item.Path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (lf *localFinder) RemoveDir(ctx context.Context, file string) error {
+	return os.RemoveAll(lf.abs(file))
+}
+
+func (lf *localFinder) RemoveFile(ctx context.Context, file string) error {
+	return os.Remove(lf.abs(file))
+}
+
+func (lf *localFinder) Rename(ctx context.Context, oldPathName, newName, path string) error {
+	if blockOperation("rename", path, oldPathName) {
+		return nil
+	}
+	if err := checkName("rename", newName); err != nil {
+		return err
+	}
+
+	return lf.rename(oldPathName, replaceLastPart(oldPathName, newName))
+}
+
+// rename os.Rename 会直接覆盖已存在的文件，与其他实现保持一致拒绝覆盖
+func (lf *localFinder) rename(oldPath, newPath string) error {
+	if _, err := os.Lstat(lf.abs(newPath)); err == nil {
+		return pathError("rename", newPath, fs.ErrExist)
+	}
+
+	return os.Rename(lf.abs(oldPath), lf.abs(newPath))
+}
+
+func (lf *localFinder) NewFolder(ctx context.Context, file string, name string) error {
+	return os.MkdirAll(lf.abs(filepath.Join(file, name)), 0755)
+}
+
+func (lf *localFinder) NewFile(ctx context.Context, file string, name string) error {
+	f, err := os.Create(lf.abs(filepath.Join(file, name)))
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (lf *localFinder) Download(ctx context.Context, filePath string) (Content, error) {
+	return lf.open(filePath)
+}
+
+func (lf *localFinder) open(path string) (Content, error) {
+	file, err := os.Open(lf.abs(path))
+	if err != nil {
+		return Content{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return Content{}, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		return Content{}, pathError("read", path, fs.ErrInvalid)
+	}
+
+	return Content{
+		ReadSeekCloser: file,
+		Name:           info.Name(),
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+	}, nil
+}
+
+func (lf *localFinder) Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error {
+	remoteDir, remoteFile = parseFilePath(remoteDir, remoteFile)
+
+	if err := os.MkdirAll(lf.abs(remoteDir), 0755); err != nil {
+		return err
+	}
+
+	// 打开源文件
+	srcFile, err := src.Open()
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	// 创建并打开目标文件
+	dstFile, err := os.Create(lf.abs(remoteFile))
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	return err
+}
+
+func (lf *localFinder) Index(ctx context.Context, adapter, path string) (Storages, error) {
+	var (
+		storages   []string
+		files      []FileInfo
+		err        error
+		dirName    string
+		newAdapter string
+	)
+
+	// 获取跟目录数据
+	if storages, err = lf.findStorage(); err != nil {
+		return Storages{}, err
+	}
+
+	if adapter != "null" {
+		newAdapter = adapter
+		dirName = getPath(newAdapter, path)
+	} else {
+		// 第一次请求没有工作目录的概念，默认进入第一个存储目录
+		dirName = "/"
+		if len(storages) > 0 {
+			dirName = fmt.Sprintf("/%s", storages[0])
+		}
+		newAdapter = getFirstPathPart(dirName)
+	}
+
+	if files, err = lf.scanFiles(dirName, newAdapter); err != nil {
+		return Storages{}, err
+	}
+
+	return Storages{
+		Adapter:  newAdapter,
+		Storages: storages,
+		Dirname:  dirName,
+		Files:    files,
+	}, nil
+}
+
+func (lf *localFinder) findStorage() ([]string, error) {
+	entries, err := os.ReadDir(lf.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var storages []string
+	for _, entry := range entries {
+		// 不是目录同时也不是软连接文件直接退出，默认跟目录不允许存储文件
+		if !entry.IsDir() && entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		storages = append(storages, entry.Name())
+	}
+
+	return storages, nil
+}
+
+// scan 查找指定路径下所有文件
+func (lf *localFinder) scan(path, adapter string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(lf.abs(path))
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfos := make([]FileInfo, 0, len(entries))
+	names := lf.accounts.get(func(name string) (io.ReadCloser, error) {
+		return os.Open(name)
+	})
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		file := names.fill(convertToFileInfo(info, path, adapter))
+		if info.Mode()&fs.ModeSymlink != 0 {
+			file = resolveLink(file, localSource{lf: lf})
+		}
+		fileInfos = append(fileInfos, file)
+	}
+
+	return fileInfos, nil
+}
+
+func (lf *localFinder) scanFiles(path, adapter string) ([]FileInfo, error) {
+	fileInfos := make([]FileInfo, 0)
+	// 不是跟目录的情况下进行添
+	if matchPath(path) {
+		fileInfos = append(fileInfos, FileInfo{
+			Basename: ".",
+			Type:     DIR,
+			Path:     path,
+		})
+		fileInfos = append(fileInfos, FileInfo{
+			Basename: "..",
+			Type:     DIR,
+			Path:     filepath.Dir(path),
+		})
+	}
+
+	files, err := lf.scan(path, adapter)
+	fileInfos = append(fileInfos, files...)
+	return fileInfos, err
+}
@@ -0,0 +1,748 @@
+package finder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ecodeclub/ekit/slice"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memNode 内存文件树节点，目录通过 children 保存子节点
+type memNode struct {
+	name     string
+	mode     os.FileMode
+	modTime  time.Time
+	content  []byte
+	children map[string]*memNode
+}
+
+func newMemDir(name string) *memNode {
+	return &memNode{
+		name:     name,
+		mode:     os.ModeDir | 0755,
+		modTime:  time.Now(),
+		children: make(map[string]*memNode),
+	}
+}
+
+func newMemFile(name string, content []byte) *memNode {
+	return &memNode{
+		name:    name,
+		mode:    0644,
+		modTime: time.Now(),
+		content: content,
+	}
+}
+
+func (n *memNode) Name() string       { return n.name }
+func (n *memNode) Size() int64        { return int64(len(n.content)) }
+func (n *memNode) Mode() os.FileMode  { return n.mode }
+func (n *memNode) ModTime() time.Time { return n.modTime }
+func (n *memNode) IsDir() bool        { return n.mode.IsDir() }
+func (n *memNode) Sys() any           { return nil }
+
+// clone 深度复制节点，文件内容写入时总是替换整个切片，可以直接共享
+func (n *memNode) clone(name string) *memNode {
+	c := &memNode{
+		name:    name,
+		mode:    n.mode,
+		modTime: time.Now(),
+		content: n.content,
+	}
+
+	if n.IsDir() {
+		c.children = make(map[string]*memNode, len(n.children))
+		for childName, child := range n.children {
+			c.children[childName] = child.clone(childName)
+		}
+	}
+
+	return c
+}
+
+// sortedChildren 按名称排序返回子节点，保证输出稳定
+func (n *memNode) sortedChildren() []*memNode {
+	children := make([]*memNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// memReader 内存内容无需释放资源
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error { return nil }
+
+type memoryFinder struct {
+	mu   sync.RWMutex
+	root *memNode
+}
+
+// NewMemoryFinder 基于内存文件树的 Finder，用于测试以及无依赖的演示环境
+func NewMemoryFinder() Finder {
+	return &memoryFinder{
+		root: newMemDir("/"),
+	}
+}
+
+// splitMemPath 拆分路径，根目录返回空切片
+func splitMemPath(p string) []string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+func (mf *memoryFinder) lookup(p string) (*memNode, error) {
+	node := mf.root
+	for _, part := range splitMemPath(p) {
+		if !node.IsDir() {
+			return nil, pathError("stat", p, fs.ErrNotExist)
+		}
+
+		child, ok := node.children[part]
+		if !ok {
+			return nil, pathError("stat", p, fs.ErrNotExist)
+		}
+		node = child
+	}
+
+	return node, nil
+}
+
+// lookupParent 获取父目录节点以及文件名称
+func (mf *memoryFinder) lookupParent(p string) (*memNode, string, error) {
+	parts := splitMemPath(p)
+	if len(parts) == 0 {
+		return nil, "", pathError("open", p, fs.ErrInvalid)
+	}
+
+	parent, err := mf.lookup(path.Join(append([]string{"/"}, parts[:len(parts)-1]...)...))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !parent.IsDir() {
+		return nil, "", pathError("open", p, fs.ErrNotExist)
+	}
+
+	return parent, parts[len(parts)-1], nil
+}
+
+func (mf *memoryFinder) mkdirAll(p string) error {
+	node := mf.root
+	for _, part := range splitMemPath(p) {
+		child, ok := node.children[part]
+		if !ok {
+			child = newMemDir(part)
+			node.children[part] = child
+			node.modTime = time.Now()
+		}
+
+		if !child.IsDir() {
+			return pathError("mkdir", p, fs.ErrExist)
+		}
+		node = child
+	}
+
+	return nil
+}
+
+// writeFile 写入文件内容，父目录必须存在，已存在的文件会被覆盖
+func (mf *memoryFinder) writeFile(p string, content []byte) error {
+	parent, name, err := mf.lookupParent(p)
+	if err != nil {
+		return err
+	}
+
+	if node, ok := parent.children[name]; ok {
+		if node.IsDir() {
+			return pathError("open", p, fs.ErrExist)
+		}
+
+		node.content = content
+		node.modTime = time.Now()
+		return nil
+	}
+
+	parent.children[name] = newMemFile(name, content)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (mf *memoryFinder) remove(p string, recursive bool) error {
+	parent, name, err := mf.lookupParent(p)
+	if err != nil {
+		return err
+	}
+
+	node, ok := parent.children[name]
+	if !ok {
+		return pathError("remove", p, fs.ErrNotExist)
+	}
+
+	if node.IsDir() && len(node.children) > 0 && !recursive {
+		return pathError("remove", p, fs.ErrExist)
+	}
+
+	delete(parent.children, name)
+	parent.modTime = time.Now()
+	return nil
+}
+
+func (mf *memoryFinder) rename(oldPath, newPath string) error {
+	oldParent, oldName, err := mf.lookupParent(oldPath)
+	if err != nil {
+		return err
+	}
+
+	node, ok := oldParent.children[oldName]
+	if !ok {
+		return pathError("rename", oldPath, fs.ErrNotExist)
+	}
+
+	newParent, newName, err := mf.lookupParent(newPath)
+	if err != nil {
+		return err
+	}
+
+	if _, ok = newParent.children[newName]; ok {
+		return pathError("rename", newPath, fs.ErrExist)
+	}
+
+	// 不允许将目录移动到自身或者子目录下
+	if node.IsDir() && strings.HasPrefix(path.Clean(newPath)+"/", path.Clean(oldPath)+"/") {
+		return pathError("rename", newPath, fs.ErrInvalid)
+	}
+
+	delete(oldParent.children, oldName)
+	node.name = newName
+	newParent.children[newName] = node
+	oldParent.modTime = time.Now()
+	newParent.modTime = time.Now()
+	return nil
+}
+
+func (mf *memoryFinder) Save(ctx context.Context, path, content string) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.writeFile(path, []byte(content))
+}
+
+func (mf *memoryFinder) Put(ctx context.Context, p string, content io.Reader, size int64) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err = mf.mkdirAll(path.Dir(p)); err != nil {
+		return err
+	}
+
+	return mf.writeFile(p, data)
+}
+
+func (mf *memoryFinder) Subfolders(ctx context.Context, adapter, path string) ([]FileInfo, error) {
+	if strings.Contains(path, "://") {
+		split := strings.Split(path, "://")
+		if split[1] == "" {
+			path = fmt.Sprintf("/%s", adapter)
+		} else {
+			path = split[1]
+		}
+	}
+
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	files, err := mf.scan(path, adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	files = slice.FilterMap(files, func(idx int, src FileInfo) (FileInfo, bool) {
+		if src.Type != DIR {
+			return src, false
+		}
+
+		return src, true
+	})
+
+	return files, nil
+}
+
+func (mf *memoryFinder) Preview(ctx context.Context, path string) (Content, error) {
+	return mf.open(path)
+}
+
+// Search 从当前目录开始逐层搜索，当前目录的列表复用 Index 的结果
+func (mf *memoryFinder) Search(ctx context.Context, adapter, path string, opts SearchOptions) (Storages, error) {
+	storage, err := mf.Index(ctx, adapter, path)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	storage.Files, err = search(ctx, storage.Files, opts, func(dir string) ([]FileInfo, error) {
+		mf.mu.RLock()
+		defer mf.mu.RUnlock()
+
+		return mf.scan(dir, storage.Adapter)
+	})
+	if err != nil {
+		return Storages{}, err
+	}
+
+	return storage, nil
+}
+
+// Grep 逐个读取文件查找匹配的行
+func (mf *memoryFinder) Grep(ctx context.Context, path string, opts GrepOptions) ([]GrepMatch, error) {
+	scan := func(dir string) ([]FileInfo, error) {
+		mf.mu.RLock()
+		defer mf.mu.RUnlock()
+
+		return mf.scan(dir, "")
+	}
+
+	return grepFiles(ctx, path, opts, scan, mf.open)
+}
+
+func (mf *memoryFinder) Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error {
+	// 判断是否有后缀，如果没有自行添加上
+	name, format, err := archiveName(path.Join(base, target), format)
+	if err != nil {
+		return err
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	var buff bytes.Buffer
+	if err = pack(ctx, memSource{mf: mf}, items, base, &buff, format); err != nil {
+		return err
+	}
+
+	return mf.writeFile(name, buff.Bytes())
+}
+
+// memSource 调用方需要持有锁，内存文件树没有软链接
+type memSource struct {
+	mf *memoryFinder
+}
+
+func (s memSource) Lstat(p string) (fs.FileInfo, error) {
+	return s.mf.lookup(p)
+}
+
+func (s memSource) Stat(p string) (fs.FileInfo, error) {
+	return s.mf.lookup(p)
+}
+
+func (s memSource) ReadLink(p string) (string, error) {
+	return "", pathError("readlink", p, fs.ErrInvalid)
+}
+
+func (s memSource) ReadDir(p string) ([]fs.FileInfo, error) {
+	node, err := s.mf.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+
+	children := node.sortedChildren()
+	infos := make([]fs.FileInfo, len(children))
+	for i, child := range children {
+		infos[i] = child
+	}
+
+	return infos, nil
+}
+
+func (s memSource) Open(p string) (io.ReadCloser, error) {
+	node, err := s.mf.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return memReader{bytes.NewReader(node.content)}, nil
+}
+
+func (mf *memoryFinder) Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error {
+	content, err := mf.open(item)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return unarchive(ctx, content, target, policy, memTarget{mf: mf})
+}
+
+// memTarget 每次操作单独加锁，解压过程中不会长时间阻塞其他请求
+type memTarget struct {
+	mf *memoryFinder
+}
+
+func (t memTarget) Lstat(p string) (fs.FileInfo, error) {
+	t.mf.mu.RLock()
+	defer t.mf.mu.RUnlock()
+
+	return t.mf.lookup(p)
+}
+
+func (t memTarget) MkdirAll(p string) error {
+	t.mf.mu.Lock()
+	defer t.mf.mu.Unlock()
+
+	return t.mf.mkdirAll(p)
+}
+
+func (t memTarget) Create(p string) (io.WriteCloser, error) {
+	return &memWriter{mf: t.mf, path: p}, nil
+}
+
+func (t memTarget) RemoveAll(p string) error {
+	t.mf.mu.Lock()
+	defer t.mf.mu.Unlock()
+
+	return t.mf.remove(p, true)
+}
+
+func (t memTarget) Chmod(p string, mode fs.FileMode) error {
+	t.mf.mu.Lock()
+	defer t.mf.mu.Unlock()
+
+	node, err := t.mf.lookup(p)
+	if err != nil {
+		return err
+	}
+
+	node.mode = node.mode.Type() | mode.Perm()
+	return nil
+}
+
+// memWriter 关闭时一次性写入文件内容
+type memWriter struct {
+	bytes.Buffer
+	mf   *memoryFinder
+	path string
+}
+
+func (w *memWriter) Close() error {
+	w.mf.mu.Lock()
+	defer w.mf.mu.Unlock()
+
+	return w.mf.writeFile(w.path, w.Bytes())
+}
+
+func (mf *memoryFinder) Move(ctx context.Context, items []Item, target string) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	for _, item := range items {
+		destPath := path.Join(target, path.Base(item.Path))
+		if err := mf.rename(item.Path, destPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mf *memoryFinder) Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return copyItems(ctx, memSource{mf: mf}, memTree{mf: mf}, items, target, policy, func(from, to string, info fs.FileInfo, merge bool) error {
+		return mf.copyNode(info.(*memNode), to)
+	})
+}
+
+func (mf *memoryFinder) Chmod(ctx context.Context, items []Item, mode string, recursive bool) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return chmodTree(ctx, memSource{mf: mf}, items, mode, recursive, func(p string, mode fs.FileMode) error {
+		node, err := mf.lookup(p)
+		if err != nil {
+			return err
+		}
+
+		node.mode = node.mode.Type() | mode
+		return nil
+	})
+}
+
+// Chown 内存文件没有属主
+func (mf *memoryFinder) Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error {
+	return fmt.Errorf("chown: %w", errors.ErrUnsupported)
+}
+
+func (mf *memoryFinder) Capabilities() Capabilities {
+	c := allCapabilities()
+	c.Chown = false
+	return c
+}
+
+// copyNode 复制节点到 to，目标为已存在的目录时合并，其余已存在的文件直接替换
+func (mf *memoryFinder) copyNode(node *memNode, to string) error {
+	parent, name, err := mf.lookupParent(to)
+	if err != nil {
+		return err
+	}
+
+	if existing, ok := parent.children[name]; ok && existing.IsDir() && node.IsDir() {
+		for _, child := range node.sortedChildren() {
+			if err = mf.copyNode(child, path.Join(to, child.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	parent.children[name] = node.clone(name)
+	parent.modTime = time.Now()
+	return nil
+}
+
+// memTree 复制时处理冲突使用，调用方需要持有锁
+type memTree struct {
+	mf *memoryFinder
+}
+
+func (t memTree) Lstat(p string) (fs.FileInfo, error) {
+	return t.mf.lookup(p)
+}
+
+func (t memTree) RemoveAll(p string) error {
+	return t.mf.remove(p, true)
+}
+
+func (mf *memoryFinder) Remove(ctx context.Context, items []Item, path string) error {
+	for _, item := range items {
+		if blockOperation("remove", path, item.Path) {
+			continue
+		}
+
+		switch item.Type {
+		case DIR:
+			err := mf.RemoveDir(ctx, item.Path)
+			if err != nil {
+				return err
+			}
+		case FILE:
+			err := mf.RemoveFile(ctx, item.Path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (mf *memoryFinder) RemoveDir(ctx context.Context, file string) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.remove(file, true)
+}
+
+func (mf *memoryFinder) RemoveFile(ctx context.Context, file string) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.remove(file, false)
+}
+
+func (mf *memoryFinder) Rename(ctx context.Context, oldPathName, newName, path string) error {
+	if blockOperation("rename", path, oldPathName) {
+		return nil
+	}
+	if err := checkName("rename", newName); err != nil {
+		return err
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.rename(oldPathName, replaceLastPart(oldPathName, newName))
+}
+
+func (mf *memoryFinder) NewFolder(ctx context.Context, file string, name string) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.mkdirAll(path.Join(file, name))
+}
+
+func (mf *memoryFinder) NewFile(ctx context.Context, file string, name string) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.writeFile(path.Join(file, name), nil)
+}
+
+func (mf *memoryFinder) Download(ctx context.Context, filePath string) (Content, error) {
+	return mf.open(filePath)
+}
+
+func (mf *memoryFinder) open(p string) (Content, error) {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	node, err := mf.lookup(p)
+	if err != nil {
+		return Content{}, err
+	}
+
+	if node.IsDir() {
+		return Content{}, pathError("read", p, fs.ErrInvalid)
+	}
+
+	// 写入时总是替换整个切片，不会修改已有内容，可以直接共享
+	return Content{
+		ReadSeekCloser: memReader{bytes.NewReader(node.content)},
+		Name:           node.name,
+		Size:           node.Size(),
+		ModTime:        node.modTime,
+	}, nil
+}
+
+func (mf *memoryFinder) Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error {
+	remoteDir, remoteFile = parseFilePath(remoteDir, remoteFile)
+
+	// 打开源文件
+	srcFile, err := src.Open()
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	content, err := io.ReadAll(srcFile)
+	if err != nil {
+		return err
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err = mf.mkdirAll(remoteDir); err != nil {
+		return err
+	}
+
+	return mf.writeFile(remoteFile, content)
+}
+
+func (mf *memoryFinder) Index(ctx context.Context, adapter, path string) (Storages, error) {
+	var (
+		files      []FileInfo
+		err        error
+		dirName    string
+		newAdapter string
+	)
+
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	// 获取跟目录数据
+	storages := mf.findStorage()
+
+	if adapter != "null" {
+		newAdapter = adapter
+		dirName = getPath(newAdapter, path)
+	} else {
+		// 第一次请求没有工作目录的概念，默认进入第一个存储目录
+		dirName = "/"
+		if len(storages) > 0 {
+			dirName = fmt.Sprintf("/%s", storages[0])
+		}
+		newAdapter = getFirstPathPart(dirName)
+	}
+
+	if files, err = mf.scanFiles(dirName, newAdapter); err != nil {
+		return Storages{}, err
+	}
+
+	return Storages{
+		Adapter:  newAdapter,
+		Storages: storages,
+		Dirname:  dirName,
+		Files:    files,
+	}, nil
+}
+
+func (mf *memoryFinder) findStorage() []string {
+	var storages []string
+	for _, child := range mf.root.sortedChildren() {
+		// 默认跟目录不允许存储文件
+		if !child.IsDir() {
+			continue
+		}
+
+		storages = append(storages, child.name)
+	}
+
+	return storages
+}
+
+// scan 查找指定路径下所有文件
+func (mf *memoryFinder) scan(p, adapter string) ([]FileInfo, error) {
+	node, err := mf.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if !node.IsDir() {
+		return nil, pathError("readdir", p, fs.ErrInvalid)
+	}
+
+	fileInfos := make([]FileInfo, 0, len(node.children))
+	for _, child := range node.sortedChildren() {
+		fileInfos = append(fileInfos, convertToFileInfo(child, p, adapter))
+	}
+
+	return fileInfos, nil
+}
+
+func (mf *memoryFinder) scanFiles(p, adapter string) ([]FileInfo, error) {
+	fileInfos := make([]FileInfo, 0)
+	// 不是跟目录的情况下进行添
+	if matchPath(p) {
+		fileInfos = append(fileInfos, FileInfo{
+			Basename: ".",
+			Type:     DIR,
+			Path:     p,
+		})
+		fileInfos = append(fileInfos, FileInfo{
+			Basename: "..",
+			Type:     DIR,
+			Path:     path.Dir(p),
+		})
+	}
+
+	files, err := mf.scan(p, adapter)
+	fileInfos = append(fileInfos, files...)
+	return fileInfos, err
+}
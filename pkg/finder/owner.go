@@ -0,0 +1,108 @@
+package finder
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/pkg/sftp"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	passwdFile = "/etc/passwd"
+	groupFile  = "/etc/group"
+)
+
+// accounts uid 以及 gid 对应的名称
+type accounts struct {
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+// fill 填充属主以及属组的名称，找不到时只保留数字 id
+func (a *accounts) fill(file FileInfo) FileInfo {
+	if file.Uid != nil {
+		file.Owner = a.users[*file.Uid]
+	}
+	if file.Gid != nil {
+		file.Group = a.groups[*file.Gid]
+	}
+
+	return file
+}
+
+// accountCache 从 /etc/passwd 以及 /etc/group 加载名称，每个会话只加载一次
+// 读取失败时同样缓存空结果，例如限制在 chroot 中的 SFTP 账号，避免每次列表都重复读取
+type accountCache struct {
+	mu       sync.Mutex
+	accounts *accounts
+}
+
+func (c *accountCache) get(open func(name string) (io.ReadCloser, error)) *accounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accounts == nil {
+		c.accounts = &accounts{users: readIds(open, passwdFile), groups: readIds(open, groupFile)}
+	}
+
+	return c.accounts
+}
+
+// readIds 解析 name:password:id: 开头的行，passwd 以及 group 的格式相同
+func readIds(open func(name string) (io.ReadCloser, error), name string) map[uint32]string {
+	ids := make(map[uint32]string)
+	file, err := open(name)
+	if err != nil {
+		slog.Warn("读取用户信息失败，只展示数字 id", slog.String("file", name), slog.Any("err", err))
+		return ids
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		// 相同 id 以第一条为准，与 getpwuid 一致
+		if _, ok := ids[uint32(id)]; !ok {
+			ids[uint32(id)] = fields[0]
+		}
+	}
+
+	return ids
+}
+
+// fileOwner SFTP 从文件属性中获取，本机文件由各个平台的 sysOwner 获取
+func fileOwner(info fs.FileInfo) (uint32, uint32, bool) {
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		return stat.UID, stat.GID, true
+	}
+
+	return sysOwner(info)
+}
+
+// visibility 其他用户可读时为 public，与 VueFinder 的取值保持一致
+func visibility(mode fs.FileMode) string {
+	if mode.Perm()&0o004 != 0 {
+		return "public"
+	}
+
+	return "private"
+}
+
+// octal 权限位的八进制表示，包含 setuid、setgid 以及 sticky 位，例如 0755、4755
+func octal(mode fs.FileMode) string {
+	return fmt.Sprintf("%04o", unixPerm(mode))
+}
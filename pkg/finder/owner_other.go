@@ -0,0 +1,10 @@
+//go:build !unix
+
+package finder
+
+import "io/fs"
+
+// sysOwner 非 unix 平台没有 uid 以及 gid
+func sysOwner(info fs.FileInfo) (uint32, uint32, bool) {
+	return 0, 0, false
+}
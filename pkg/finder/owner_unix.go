@@ -0,0 +1,16 @@
+//go:build unix
+
+package finder
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func sysOwner(info fs.FileInfo) (uint32, uint32, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Uid, stat.Gid, true
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,203 @@
+package finder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidCursor 游标无法解析，或者与当前的排序方式不一致
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortField 列表的排序字段
+type SortField string
+
+const (
+	SortName SortField = "name"
+	SortSize SortField = "size"
+	// SortModified 按照修改时间排序
+	SortModified SortField = "mtime"
+	// SortType 目录在前，文件按照扩展名排序
+	SortType SortField = "type"
+)
+
+// ListOptions 排序以及分页条件，零值表示保持原有顺序返回全部条目
+type ListOptions struct {
+	// Sort 未指定时按照名称排序
+	Sort SortField
+	Desc bool
+	// Offset 跳过的条目数量，设置 Cursor 时忽略
+	Offset int
+	// Limit 每页的数量，0 表示返回剩余的全部条目
+	Limit int
+	// Cursor 上一页返回的 NextCursor，翻页期间新增或者删除条目不会导致重复或者遗漏
+	Cursor string
+}
+
+// Pagination 分页信息，数量不包含 . 以及 .. 导航条目
+type Pagination struct {
+	Total      int    `json:"total"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursor 记录上一页最后一个条目的排序字段
+type cursor struct {
+	Sort     SortField `json:"s"`
+	Desc     bool      `json:"d,omitempty"`
+	Type     FileType  `json:"t"`
+	Path     string    `json:"p"`
+	Basename string    `json:"n"`
+	Ext      string    `json:"e,omitempty"`
+	Size     int64     `json:"z,omitempty"`
+	Modified int64     `json:"m,omitempty"`
+}
+
+func (c cursor) file() FileInfo {
+	return FileInfo{Type: c.Type, Path: c.Path, Basename: c.Basename, Extension: c.Ext, FileSize: c.Size, LastModified: c.Modified}
+}
+
+// Paginate 排序后返回其中一页，导航条目始终位于每一页的最前面
+func Paginate(files []FileInfo, opts ListOptions) ([]FileInfo, *Pagination, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortName
+	}
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return nil, nil, fmt.Errorf("invalid offset %d or limit %d", opts.Offset, opts.Limit)
+	}
+
+	less, err := fileLess(opts.Sort, opts.Desc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dots, entries []FileInfo
+	for _, file := range files {
+		if file.Basename == "." || file.Basename == ".." {
+			dots = append(dots, file)
+			continue
+		}
+		entries = append(entries, file)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+
+	start := opts.Offset
+	if opts.Cursor != "" {
+		last, err := decodeCursor(opts.Cursor, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = sort.Search(len(entries), func(i int) bool {
+			return less(last, entries[i])
+		})
+	}
+
+	start = min(start, len(entries))
+	end := len(entries)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, end)
+	}
+
+	page := &Pagination{Total: len(entries), Offset: start, Limit: opts.Limit}
+	if end < len(entries) {
+		page.NextCursor = encodeCursor(entries[end-1], opts)
+	}
+
+	res := make([]FileInfo, 0, len(dots)+end-start)
+	res = append(res, dots...)
+	return append(res, entries[start:end]...), page, nil
+}
+
+// fileLess 字段相同时按照路径排序，保证顺序稳定，游标才能准确定位
+func fileLess(field SortField, desc bool) (func(a, b FileInfo) bool, error) {
+	var compare func(a, b FileInfo) int
+	switch field {
+	case SortName:
+		compare = compareName
+	case SortSize:
+		compare = func(a, b FileInfo) int {
+			return compareInt(a.FileSize, b.FileSize)
+		}
+	case SortModified:
+		compare = func(a, b FileInfo) int {
+			return compareInt(a.LastModified, b.LastModified)
+		}
+	case SortType:
+		compare = func(a, b FileInfo) int {
+			if a.Type != b.Type {
+				if a.Type == DIR {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(strings.ToLower(a.Extension), strings.ToLower(b.Extension))
+		}
+	default:
+		return nil, fmt.Errorf("unknown sort field %q", field)
+	}
+
+	return func(a, b FileInfo) bool {
+		c := compare(a, b)
+		if c == 0 {
+			c = compareName(a, b)
+		}
+		if c == 0 {
+			c = strings.Compare(a.Path, b.Path)
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}, nil
+}
+
+// compareName 忽略大小写比较名称，相同时再区分大小写
+func compareName(a, b FileInfo) int {
+	if c := strings.Compare(strings.ToLower(a.Basename), strings.ToLower(b.Basename)); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.Basename, b.Basename)
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func encodeCursor(file FileInfo, opts ListOptions) string {
+	data, _ := json.Marshal(cursor{
+		Sort: opts.Sort, Desc: opts.Desc,
+		Type: file.Type, Path: file.Path, Basename: file.Basename, Ext: file.Extension,
+		Size: file.FileSize, Modified: file.LastModified,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, opts ListOptions) (FileInfo, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return FileInfo{}, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.Sort != opts.Sort || c.Desc != opts.Desc {
+		return FileInfo{}, ErrInvalidCursor
+	}
+
+	return c.file(), nil
+}
@@ -0,0 +1,134 @@
+package finder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+)
+
+// ErrReadOnly 只读会话不允许修改文件，可以通过 errors.Is(err, fs.ErrPermission) 判断
+var ErrReadOnly = fmt.Errorf("read-only session: %w", fs.ErrPermission)
+
+// Capabilities 会话支持的操作，前端据此隐藏不可用的按钮
+type Capabilities struct {
+	ReadOnly  bool `json:"read_only"`
+	Upload    bool `json:"upload"`
+	Save      bool `json:"save"`
+	NewFile   bool `json:"new_file"`
+	NewFolder bool `json:"new_folder"`
+	Rename    bool `json:"rename"`
+	Move      bool `json:"move"`
+	Copy      bool `json:"copy"`
+	Remove    bool `json:"remove"`
+	Archive   bool `json:"archive"`
+	Unarchive bool `json:"unarchive"`
+	Chmod     bool `json:"chmod"`
+	Chown     bool `json:"chown"`
+}
+
+// capabler 不支持部分操作的实现提供，没有实现时视为支持所有操作
+type capabler interface {
+	Capabilities() Capabilities
+}
+
+// allCapabilities 支持所有操作
+func allCapabilities() Capabilities {
+	return Capabilities{
+		Upload: true, Save: true, NewFile: true, NewFolder: true, Rename: true, Move: true, Copy: true,
+		Remove: true, Archive: true, Unarchive: true, Chmod: true, Chown: true,
+	}
+}
+
+// CapabilitiesOf 获取 f 支持的操作，装饰器会合并内部 Finder 的限制
+func CapabilitiesOf(f Finder) Capabilities {
+	if c, ok := f.(capabler); ok {
+		return c.Capabilities()
+	}
+
+	return allCapabilities()
+}
+
+// readOnlyFinder 浏览、搜索、下载以及预览直接交给 inner，修改文件的操作返回 ErrReadOnly
+type readOnlyFinder struct {
+	Finder
+}
+
+// NewReadOnlyFinder 只读的 Finder，用于只允许浏览以及下载的生产环境主机
+func NewReadOnlyFinder(inner Finder) Finder {
+	return &readOnlyFinder{Finder: inner}
+}
+
+func (r *readOnlyFinder) Capabilities() Capabilities {
+	return Capabilities{ReadOnly: true}
+}
+
+func (r *readOnlyFinder) Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error {
+	return pathError("upload", remoteDir, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Save(ctx context.Context, path, content string) error {
+	return pathError("save", path, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Put(ctx context.Context, path string, content io.Reader, size int64) error {
+	return pathError("put", path, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Rename(ctx context.Context, oldPathName, newName, path string) error {
+	return pathError("rename", oldPathName, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) NewFolder(ctx context.Context, file, name string) error {
+	return pathError("mkdir", file, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) NewFile(ctx context.Context, file, name string) error {
+	return pathError("create", file, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Remove(ctx context.Context, items []Item, path string) error {
+	return pathError("remove", path, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) RemoveDir(ctx context.Context, file string) error {
+	return pathError("remove", file, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) RemoveFile(ctx context.Context, file string) error {
+	return pathError("remove", file, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error {
+	return pathError("archive", base, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error {
+	return pathError("unarchive", target, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Move(ctx context.Context, items []Item, target string) error {
+	return pathError("move", target, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error {
+	return pathError("copy", target, ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Chmod(ctx context.Context, items []Item, mode string, recursive bool) error {
+	return pathError("chmod", itemsPath(items), ErrReadOnly)
+}
+
+func (r *readOnlyFinder) Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error {
+	return pathError("chown", itemsPath(items), ErrReadOnly)
+}
+
+// itemsPath 错误信息中使用的路径，取第一个文件
+func itemsPath(items []Item) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	return items[0].Path
+}
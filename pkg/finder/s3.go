@@ -0,0 +1,718 @@
+package finder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"path"
+	"strings"
+	"time"
+)
+
+type s3Finder struct {
+	client *minio.Client
+}
+
+// NewS3Finder 基于 S3 兼容对象存储的 Finder
+// 存储桶对应 Storages，前缀对应目录，对象对应文件，路径格式为 /bucket/prefix/object
+func NewS3Finder(client *minio.Client) Finder {
+	return &s3Finder{
+		client: client,
+	}
+}
+
+// splitObjectPath 拆分路径为存储桶和对象名称
+func splitObjectPath(p string) (string, string) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	bucket, key, _ := strings.Cut(p, "/")
+	return bucket, key
+}
+
+// dirPrefix 目录对应的对象前缀，存储桶根目录为空
+func dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(key, "/") + "/"
+}
+
+func (s *s3Finder) Save(ctx context.Context, filePath, content string) error {
+	bucket, key := splitObjectPath(filePath)
+	_, err := s.client.PutObject(ctx, bucket, key, strings.NewReader(content), int64(len(content)),
+		minio.PutObjectOptions{ContentType: mime.TypeByExtension(path.Ext(key))})
+	return err
+}
+
+func (s *s3Finder) Put(ctx context.Context, filePath string, content io.Reader, size int64) error {
+	bucket, key := splitObjectPath(filePath)
+	_, err := s.client.PutObject(ctx, bucket, key, content, size, putOptions(key, size))
+	return err
+}
+
+// streamPartSize 长度未知的上传使用的分片大小，未指定时 minio-go 按照最大对象大小计算，每次上传分配约 560MiB 的缓冲区
+// 最多 10000 个分片，长度未知的对象最大约 156GiB
+const streamPartSize = 16 << 20
+
+func putOptions(key string, size int64) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{ContentType: mime.TypeByExtension(path.Ext(key))}
+	if size < 0 {
+		opts.PartSize = streamPartSize
+	}
+
+	return opts
+}
+
+func (s *s3Finder) Subfolders(ctx context.Context, adapter, filePath string) ([]FileInfo, error) {
+	if strings.Contains(filePath, "://") {
+		split := strings.Split(filePath, "://")
+		if split[1] == "" {
+			filePath = fmt.Sprintf("/%s", adapter)
+		} else {
+			filePath = split[1]
+		}
+	}
+
+	files, err := s.scan(ctx, filePath, adapter)
+	if err != nil {
+		return nil, err
+	}
+
+	files = slice.FilterMap(files, func(idx int, src FileInfo) (FileInfo, bool) {
+		if src.Type != DIR {
+			return src, false
+		}
+
+		return src, true
+	})
+
+	return files, nil
+}
+
+func (s *s3Finder) Preview(ctx context.Context, filePath string) (Content, error) {
+	return s.open(ctx, filePath)
+}
+
+// Search 从当前目录开始逐层搜索，当前目录的列表复用 Index 的结果
+func (s *s3Finder) Search(ctx context.Context, adapter, filePath string, opts SearchOptions) (Storages, error) {
+	storage, err := s.Index(ctx, adapter, filePath)
+	if err != nil {
+		return Storages{}, err
+	}
+
+	storage.Files, err = search(ctx, storage.Files, opts, func(dir string) ([]FileInfo, error) {
+		return s.scan(ctx, dir, storage.Adapter)
+	})
+	if err != nil {
+		return Storages{}, err
+	}
+
+	return storage, nil
+}
+
+// Grep 逐个读取文件查找匹配的行
+func (s *s3Finder) Grep(ctx context.Context, filePath string, opts GrepOptions) ([]GrepMatch, error) {
+	scan := func(dir string) ([]FileInfo, error) {
+		return s.scan(ctx, dir, "")
+	}
+
+	return grepFiles(ctx, filePath, opts, scan, func(p string) (Content, error) {
+		return s.open(ctx, p)
+	})
+}
+
+func (s *s3Finder) Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error {
+	// 判断是否有后缀，如果没有自行添加上
+	name, format, err := archiveName(path.Join(base, target), format)
+	if err != nil {
+		return err
+	}
+	bucket, key := splitObjectPath(name)
+
+	// 边打包边上传，避免在服务端缓存整个压缩包
+	pr, pw := io.Pipe()
+	go func() {
+		aw, err := newArchiveWriter(pw, format)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		for _, item := range items {
+			if blockOperation("archive", base, item.Path) {
+				continue
+			}
+
+			if err = s.walkArchive(ctx, item, aw, base); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+
+		pw.CloseWithError(aw.Close())
+	}()
+
+	opts := putOptions(key, -1)
+	if format == FormatZip {
+		opts.ContentType = "application/zip"
+	}
+
+	_, err = s.client.PutObject(ctx, bucket, key, pr, -1, opts)
+	// 上传失败时需要中断打包协程
+	pr.CloseWithError(err)
+	return err
+}
+
+// walkArchive 对象存储没有真实目录，目录通过前缀列出所有对象
+func (s *s3Finder) walkArchive(ctx context.Context, item Item, aw archiveWriter, basePath string) error {
+	bucket, key := splitObjectPath(item.Path)
+
+	if item.Type == FILE {
+		return s.archiveObject(ctx, bucket, key, aw, basePath)
+	}
+
+	prefix := dirPrefix(key)
+	if err := archiveDir(aw, path.Join("/", bucket, prefix), basePath); err != nil {
+		return err
+	}
+
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+
+		// 目录占位对象只需要写入目录头
+		if strings.HasSuffix(object.Key, "/") {
+			if object.Key == prefix {
+				continue
+			}
+
+			if err := archiveDir(aw, path.Join("/", bucket, object.Key), basePath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.archiveObject(ctx, bucket, object.Key, aw, basePath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func archiveDir(aw archiveWriter, dir, basePath string) error {
+	// 去掉公共前缀
+	name := strings.TrimPrefix(strings.TrimPrefix(dir, basePath), "/")
+	_, err := aw.Add(name, fileStat{name: path.Base(dir), mode: fs.ModeDir | 0755, modTime: time.Now()}, "")
+	return err
+}
+
+func (s *s3Finder) archiveObject(ctx context.Context, bucket, key string, aw archiveWriter, basePath string) error {
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	stat, err := object.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 去掉公共前缀
+	name := strings.TrimPrefix(strings.TrimPrefix(path.Join("/", bucket, key), basePath), "/")
+	writer, err := aw.Add(name, fileStat{name: path.Base(key), size: stat.Size, mode: 0644, modTime: stat.LastModified}, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, object)
+	return err
+}
+
+func (s *s3Finder) Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error {
+	content, err := s.open(ctx, item)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return unarchive(ctx, content, target, policy, s3Target{ctx: ctx, s: s})
+}
+
+// s3Target 压缩包通过 Range 请求按需读取，解压后的文件直接流式上传
+type s3Target struct {
+	ctx context.Context
+	s   *s3Finder
+}
+
+func (t s3Target) Lstat(filePath string) (fs.FileInfo, error) {
+	bucket, key := splitObjectPath(filePath)
+	stat, err := t.s.client.StatObject(t.ctx, bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return fileStat{name: path.Base(key), size: stat.Size, mode: 0644, modTime: stat.LastModified}, nil
+	}
+
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return nil, err
+	}
+
+	isDir, err := t.s.isDir(t.ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		return nil, pathError("stat", filePath, fs.ErrNotExist)
+	}
+
+	return fileStat{name: path.Base(key), mode: fs.ModeDir | 0755}, nil
+}
+
+func (t s3Target) MkdirAll(filePath string) error {
+	_, key := splitObjectPath(filePath)
+	if key == "" {
+		return nil
+	}
+
+	isDir, err := t.s.isDir(t.ctx, filePath)
+	if err != nil || isDir {
+		return err
+	}
+
+	return t.s.NewFolder(t.ctx, filePath, "")
+}
+
+func (t s3Target) Create(filePath string) (io.WriteCloser, error) {
+	bucket, key := splitObjectPath(filePath)
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := t.s.client.PutObject(t.ctx, bucket, key, reader, -1, putOptions(key, -1))
+		_ = reader.CloseWithError(err)
+		done <- err
+	}()
+
+	return &pipeUpload{PipeWriter: writer, done: done}, nil
+}
+
+func (t s3Target) RemoveAll(filePath string) error {
+	if err := t.s.RemoveFile(t.ctx, filePath); err != nil {
+		return err
+	}
+
+	return t.s.RemoveDir(t.ctx, filePath)
+}
+
+// pipeUpload 关闭时等待上传完成
+type pipeUpload struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (u *pipeUpload) Close() error {
+	if err := u.PipeWriter.Close(); err != nil {
+		return err
+	}
+
+	return <-u.done
+}
+
+// CloseWithError 上传读取到 err 后放弃分片上传，不会提交已写入的部分内容
+func (u *pipeUpload) CloseWithError(err error) error {
+	if cErr := u.PipeWriter.CloseWithError(err); cErr != nil {
+		return cErr
+	}
+
+	<-u.done
+	return err
+}
+
+// Chmod 对象存储没有 POSIX 权限
+func (s *s3Finder) Chmod(ctx context.Context, items []Item, mode string, recursive bool) error {
+	return fmt.Errorf("chmod: %w", errors.ErrUnsupported)
+}
+
+// Chown 对象存储没有属主
+func (s *s3Finder) Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error {
+	return fmt.Errorf("chown: %w", errors.ErrUnsupported)
+}
+
+func (s *s3Finder) Capabilities() Capabilities {
+	c := allCapabilities()
+	c.Chmod, c.Chown = false, false
+	return c
+}
+
+func (s *s3Finder) Move(ctx context.Context, items []Item, target string) error {
+	for _, item := range items {
+		destPath := path.Join(target, path.Base(item.Path))
+		if err := s.moveItem(ctx, item, destPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveItem 对象存储不支持重命名，通过复制后删除实现
+func (s *s3Finder) moveItem(ctx context.Context, item Item, destPath string) error {
+	// 与其他实现保持一致，目标已存在时拒绝覆盖
+	exist, err := s.exists(ctx, destPath)
+	if err != nil {
+		return err
+	}
+	if exist {
+		return pathError("rename", destPath, fs.ErrExist)
+	}
+
+	if err = s.copyItem(ctx, item, destPath); err != nil {
+		return err
+	}
+
+	if item.Type == FILE {
+		return s.RemoveFile(ctx, item.Path)
+	}
+
+	return s.RemoveDir(ctx, item.Path)
+}
+
+// Copy 使用服务端复制，对象内容不经过本服务
+func (s *s3Finder) Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error {
+	t := s3Target{ctx: ctx, s: s}
+	return copyItems(ctx, t, t, items, target, policy, func(from, to string, info fs.FileInfo, merge bool) error {
+		item := Item{Path: from, Type: FILE}
+		if info.IsDir() {
+			item.Type = DIR
+		}
+
+		// 目录合并时同名对象直接覆盖
+		return s.copyItem(ctx, item, to)
+	})
+}
+
+// copyItem 复制对象，目录需要复制前缀下的所有对象
+func (s *s3Finder) copyItem(ctx context.Context, item Item, destPath string) error {
+	srcBucket, srcKey := splitObjectPath(item.Path)
+	dstBucket, dstKey := splitObjectPath(destPath)
+
+	if item.Type == FILE {
+		return s.copyObject(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	}
+
+	srcPrefix, dstPrefix := dirPrefix(srcKey), dirPrefix(dstKey)
+	for object := range s.client.ListObjects(ctx, srcBucket, minio.ListObjectsOptions{Prefix: srcPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+
+		dstObject := dstPrefix + strings.TrimPrefix(object.Key, srcPrefix)
+		if err := s.copyObject(ctx, srcBucket, object.Key, dstBucket, dstObject); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *s3Finder) copyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: srcBucket, Object: srcKey})
+	return err
+}
+
+func (s *s3Finder) Remove(ctx context.Context, items []Item, filePath string) error {
+	for _, item := range items {
+		if blockOperation("remove", filePath, item.Path) {
+			continue
+		}
+
+		switch item.Type {
+		case DIR:
+			err := s.RemoveDir(ctx, item.Path)
+			if err != nil {
+				return err
+			}
+		case FILE:
+			err := s.RemoveFile(ctx, item.Path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *s3Finder) RemoveDir(ctx context.Context, file string) error {
+	bucket, key := splitObjectPath(file)
+	objects := s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: dirPrefix(key), Recursive: true})
+
+	// 需要消费完所有结果，避免删除协程阻塞
+	var err error
+	for rErr := range s.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		if err == nil {
+			err = rErr.Err
+		}
+	}
+
+	return err
+}
+
+func (s *s3Finder) RemoveFile(ctx context.Context, file string) error {
+	bucket, key := splitObjectPath(file)
+	return s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Finder) Rename(ctx context.Context, oldPathName, newName, filePath string) error {
+	if blockOperation("rename", filePath, oldPathName) {
+		return nil
+	}
+	if err := checkName("rename", newName); err != nil {
+		return err
+	}
+	newPath := replaceLastPart(oldPathName, newName)
+
+	// 前端未传递类型，根据前缀下是否有对象判断是否为目录
+	item := Item{Path: oldPathName, Type: FILE}
+	isDir, err := s.isDir(ctx, oldPathName)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		item.Type = DIR
+	}
+
+	return s.moveItem(ctx, item, newPath)
+}
+
+func (s *s3Finder) exists(ctx context.Context, filePath string) (bool, error) {
+	bucket, key := splitObjectPath(filePath)
+	_, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return false, err
+	}
+
+	return s.isDir(ctx, filePath)
+}
+
+func (s *s3Finder) isDir(ctx context.Context, filePath string) (bool, error) {
+	bucket, key := splitObjectPath(filePath)
+	if key == "" {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: dirPrefix(key), MaxKeys: 1}) {
+		if object.Err != nil {
+			return false, object.Err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *s3Finder) NewFolder(ctx context.Context, file string, name string) error {
+	// 对象存储没有真实目录，写入以 / 结尾的空对象作为占位
+	bucket, key := splitObjectPath(path.Join(file, name))
+	_, err := s.client.PutObject(ctx, bucket, dirPrefix(key), bytes.NewReader(nil), 0, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *s3Finder) NewFile(ctx context.Context, file string, name string) error {
+	return s.Save(ctx, path.Join(file, name), "")
+}
+
+func (s *s3Finder) Download(ctx context.Context, filePath string) (Content, error) {
+	return s.open(ctx, filePath)
+}
+
+func (s *s3Finder) open(ctx context.Context, filePath string) (Content, error) {
+	bucket, key := splitObjectPath(filePath)
+	object, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return Content{}, err
+	}
+
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return Content{}, err
+	}
+
+	return Content{
+		ReadSeekCloser: object,
+		Name:           path.Base(key),
+		Size:           stat.Size,
+		ModTime:        stat.LastModified,
+	}, nil
+}
+
+func (s *s3Finder) Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error {
+	_, remoteFile = parseFilePath(remoteDir, remoteFile)
+
+	// 打开源文件
+	srcFile, err := src.Open()
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	bucket, key := splitObjectPath(remoteFile)
+	_, err = s.client.PutObject(ctx, bucket, key, srcFile, src.Size,
+		minio.PutObjectOptions{ContentType: mime.TypeByExtension(path.Ext(key))})
+	return err
+}
+
+func (s *s3Finder) Index(ctx context.Context, adapter, filePath string) (Storages, error) {
+	var (
+		storages   []string
+		files      []FileInfo
+		err        error
+		dirName    string
+		newAdapter string
+	)
+
+	// 获取所有存储桶
+	if storages, err = s.findStorage(ctx); err != nil {
+		return Storages{}, err
+	}
+
+	if adapter != "null" {
+		newAdapter = adapter
+		dirName = getPath(newAdapter, filePath)
+	} else {
+		// 第一次请求默认进入第一个存储桶
+		dirName = "/"
+		if len(storages) > 0 {
+			dirName = fmt.Sprintf("/%s", storages[0])
+		}
+		newAdapter = getFirstPathPart(dirName)
+	}
+
+	if files, err = s.scanFiles(ctx, dirName, newAdapter); err != nil {
+		return Storages{}, err
+	}
+
+	return Storages{
+		Adapter:  newAdapter,
+		Storages: storages,
+		Dirname:  dirName,
+		Files:    files,
+	}, nil
+}
+
+func (s *s3Finder) findStorage(ctx context.Context) ([]string, error) {
+	buckets, err := s.client.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return slice.Map(buckets, func(idx int, src minio.BucketInfo) string {
+		return src.Name
+	}), nil
+}
+
+// scan 查找指定前缀下的所有对象以及子目录
+func (s *s3Finder) scan(ctx context.Context, dir, adapter string) ([]FileInfo, error) {
+	bucket, key := splitObjectPath(dir)
+	prefix := dirPrefix(key)
+	dir = path.Join("/", bucket, prefix)
+
+	var (
+		found     bool
+		seen      = make(map[string]struct{})
+		fileInfos = make([]FileInfo, 0)
+	)
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+
+		// 跳过目录自身的占位对象
+		found = true
+		if object.Key == prefix {
+			continue
+		}
+
+		// 部分实现会同时返回目录占位对象以及公共前缀
+		if _, ok := seen[object.Key]; ok {
+			continue
+		}
+		seen[object.Key] = struct{}{}
+
+		fileInfos = append(fileInfos, convertObjectToFileInfo(object, dir, prefix, adapter))
+	}
+
+	// 对象存储没有真实目录，前缀下没有任何对象时视为目录不存在
+	if !found && prefix != "" {
+		return nil, pathError("readdir", dir, fs.ErrNotExist)
+	}
+
+	return fileInfos, nil
+}
+
+// convertObjectToFileInfo 对象转换为finder前端识别
+func convertObjectToFileInfo(object minio.ObjectInfo, dir, prefix, adapter string) FileInfo {
+	name := strings.TrimSuffix(strings.TrimPrefix(object.Key, prefix), "/")
+	if strings.HasSuffix(object.Key, "/") {
+		return FileInfo{
+			Type:          DIR,
+			Path:          path.Join(dir, name),
+			Visibility:    "public",
+			ExtraMetadata: []string{},
+			Basename:      name,
+			Storage:       adapter,
+		}
+	}
+
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	return FileInfo{
+		Type:          FILE,
+		Path:          path.Join(dir, name),
+		Visibility:    "public",
+		LastModified:  object.LastModified.Unix(),
+		MimeType:      mime.TypeByExtension("." + ext),
+		ExtraMetadata: []string{},
+		Basename:      name,
+		Extension:     ext,
+		Storage:       adapter,
+		FileSize:      object.Size,
+	}
+}
+
+func (s *s3Finder) scanFiles(ctx context.Context, dir, adapter string) ([]FileInfo, error) {
+	fileInfos := make([]FileInfo, 0)
+	// 不是存储桶根目录的情况下进行添加
+	if matchPath(dir) {
+		fileInfos = append(fileInfos, FileInfo{
+			Basename: ".",
+			Type:     DIR,
+			Path:     dir,
+		})
+		fileInfos = append(fileInfos, FileInfo{
+			Basename: "..",
+			Type:     DIR,
+			Path:     path.Dir(dir),
+		})
+	}
+
+	files, err := s.scan(ctx, dir, adapter)
+	fileInfos = append(fileInfos, files...)
+	return fileInfos, err
+}
@@ -0,0 +1,421 @@
+package finder_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 进程内的 S3 服务端，只实现 minio-go 使用到的接口，不校验签名
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+	uploads map[string]*fakeUpload
+	nextId  int
+}
+
+type fakeObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+type fakeUpload struct {
+	bucket string
+	key    string
+	parts  map[int][]byte
+}
+
+// newS3Client 启动 fakeS3 并创建 buckets，返回连接到该服务端的客户端
+func newS3Client(t *testing.T, buckets ...string) (*minio.Client, *fakeS3) {
+	fake := &fakeS3{buckets: make(map[string]map[string]fakeObject), uploads: make(map[string]*fakeUpload)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:        credentials.NewStaticV4("", "", ""),
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bucket := range buckets {
+		if err = client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return client, fake
+}
+
+// keys 存储桶中所有对象的名称
+func (f *fakeS3) keys(bucket string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys := make([]string, 0, len(f.buckets[bucket]))
+	for key := range f.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 请求体在加锁前读取，客户端流式上传时不会阻塞其他请求
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	switch {
+	case bucket == "":
+		f.listBuckets(w)
+	case key == "" && r.Method == http.MethodPut:
+		if _, ok := f.buckets[bucket]; !ok {
+			f.buckets[bucket] = make(map[string]fakeObject)
+		}
+	case f.buckets[bucket] == nil:
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+	case key == "" && r.Method == http.MethodGet:
+		f.listObjects(w, bucket, query)
+	case key == "" && r.Method == http.MethodPost && query.Has("delete"):
+		f.deleteObjects(w, r, bucket, body)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.createUpload(w, bucket, key)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.uploadPart(w, r, query, body)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.completeUpload(w, r, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.copyObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		w.Header().Set("ETag", f.put(bucket, key, body))
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		f.getObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		delete(f.buckets[bucket], key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) put(bucket, key string, data []byte) string {
+	f.buckets[bucket][key] = fakeObject{data: data, modTime: time.Now().UTC().Truncate(time.Second)}
+	return etag(data)
+}
+
+func (f *fakeS3) listBuckets(w http.ResponseWriter) {
+	type bucket struct {
+		Name         string
+		CreationDate time.Time
+	}
+	var result struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}
+	for name := range f.buckets {
+		result.Buckets = append(result.Buckets, bucket{Name: name, CreationDate: time.Now().UTC()})
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].Name < result.Buckets[j].Name
+	})
+
+	writeXML(w, result)
+}
+
+// listObjects ListObjectsV2，delimiter 之后的部分合并为公共前缀，continuation-token 为上一页最后一项
+func (f *fakeS3) listObjects(w http.ResponseWriter, bucket string, query url.Values) {
+	type object struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		Delimiter             string
+		MaxKeys               int
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string
+		Contents              []object
+		CommonPrefixes        []commonPrefix
+	}
+
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys, err := strconv.Atoi(query.Get("max-keys"))
+	if err != nil || maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	after := query.Get("continuation-token")
+	if after == "" {
+		after = query.Get("start-after")
+	}
+
+	keys := make([]string, 0)
+	for key := range f.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result.Name, result.Prefix, result.Delimiter, result.MaxKeys = bucket, prefix, delimiter, maxKeys
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		name, isPrefix := key, false
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			name, isPrefix = key[:len(prefix)+i+len(delimiter)], true
+		}
+		if name <= after || isPrefix && len(result.CommonPrefixes) > 0 && result.CommonPrefixes[len(result.CommonPrefixes)-1].Prefix == name {
+			continue
+		}
+
+		if result.KeyCount == maxKeys {
+			result.IsTruncated, result.NextContinuationToken = true, after
+			break
+		}
+
+		result.KeyCount++
+		after = name
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: name})
+			continue
+		}
+
+		obj := f.buckets[bucket][key]
+		result.Contents = append(result.Contents, object{Key: key, LastModified: obj.modTime, ETag: etag(obj.data), Size: int64(len(obj.data))})
+	}
+
+	writeXML(w, result)
+}
+
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string, body []byte) {
+	var req struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	for _, object := range req.Objects {
+		delete(f.buckets[bucket], object.Key)
+	}
+
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"DeleteResult"`
+	}{})
+}
+
+func (f *fakeS3) createUpload(w http.ResponseWriter, bucket, key string) {
+	f.nextId++
+	id := strconv.Itoa(f.nextId)
+	f.uploads[id] = &fakeUpload{bucket: bucket, key: key, parts: make(map[int][]byte)}
+
+	writeXML(w, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Bucket: bucket, Key: key, UploadId: id})
+}
+
+func (f *fakeS3) uploadPart(w http.ResponseWriter, r *http.Request, query url.Values, body []byte) {
+	upload, ok := f.uploads[query.Get("uploadId")]
+	number, err := strconv.Atoi(query.Get("partNumber"))
+	if !ok || err != nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	upload.parts[number] = body
+	w.Header().Set("ETag", etag(body))
+}
+
+// completeUpload 按照分片编号顺序合并，被中断的上传不会调用
+func (f *fakeS3) completeUpload(w http.ResponseWriter, r *http.Request, id string) {
+	upload, ok := f.uploads[id]
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	delete(f.uploads, id)
+
+	numbers := make([]int, 0, len(upload.parts))
+	for number := range upload.parts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	var data []byte
+	for _, number := range numbers {
+		data = append(data, upload.parts[number]...)
+	}
+
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: upload.bucket, Key: upload.key, ETag: f.put(upload.bucket, upload.key, data)})
+}
+
+func (f *fakeS3) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	src, ok := f.buckets[srcBucket][srcKey]
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	writeXML(w, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified time.Time
+	}{ETag: f.put(bucket, key, bytes.Clone(src.data)), LastModified: time.Now().UTC()})
+}
+
+// getObject Range 以及条件请求交给 http.ServeContent 处理
+func (f *fakeS3) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	object, ok := f.buckets[bucket][key]
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	w.Header().Set("ETag", etag(object.data))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, key, object.modTime, bytes.NewReader(object.data))
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+// writeS3Error HEAD 请求没有响应体，minio-go 根据状态码识别错误
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: fmt.Sprintf("%s %s", r.Method, r.URL.Path)})
+}
+
+// TestS3UnarchiveAbort 解压失败时中断上传，不能留下内容不完整的对象
+func TestS3UnarchiveAbort(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newS3Client(t, "data")
+	f := finder.NewS3Finder(client)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "big.bin", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("vuefinder"), 1024)
+	if _, err = w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 修改文件内容使校验和不一致，读取到结尾时才会返回错误
+	archive := bytes.Replace(buf.Bytes(), content[:9], []byte("VUEFINDER"), 1)
+	if err = f.Put(ctx, "/data/broken.zip", bytes.NewReader(archive), int64(len(archive))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Unarchive(ctx, "/data/broken.zip", "/data/broken", finder.ConflictError); err == nil {
+		t.Fatal("Unarchive of a corrupted zip should fail")
+	}
+
+	// 解压目录已经创建，文件不能提交
+	if keys := fake.keys("data"); slices.Contains(keys, "broken/big.bin") {
+		t.Errorf("objects = %v, the truncated upload was committed", keys)
+	}
+}
+
+// TestS3StreamingAllocations 长度未知的上传需要指定分片大小，否则每次打包或解压都会分配约 560MiB 的缓冲区
+func TestS3StreamingAllocations(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newS3Client(t, "data")
+	f := finder.NewS3Finder(client)
+	if err := f.Put(ctx, "/data/src/a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := f.Archive(ctx, []finder.Item{{Path: "/data/src", Type: finder.DIR}}, "src", "/data", finder.FormatZip); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if err := f.Unarchive(ctx, "/data/src.zip", "/data/out", ""); err != nil {
+		t.Fatalf("Unarchive: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 128<<20 {
+		t.Errorf("Archive and Unarchive allocated %dMiB", allocated>>20)
+	}
+}
@@ -0,0 +1,183 @@
+package finder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// errSearchLimit 达到数量上限，用于提前结束遍历
+var errSearchLimit = errors.New("search limit reached")
+
+const (
+	// defaultSearchLimit 未指定数量时最多返回的结果
+	defaultSearchLimit = 1000
+	// defaultSearchDepth 未指定深度时最多搜索的层级，避免软链接循环导致无法结束
+	defaultSearchDepth = 32
+)
+
+// MatchMode 文件名称的匹配方式
+type MatchMode string
+
+const (
+	// MatchContains 名称包含关键字，默认方式
+	MatchContains MatchMode = "contains"
+	// MatchGlob shell 通配符，例如 *.log
+	MatchGlob MatchMode = "glob"
+	// MatchRegex 正则表达式
+	MatchRegex MatchMode = "regex"
+)
+
+// SearchOptions 搜索条件，零值表示不限制
+type SearchOptions struct {
+	// Pattern 匹配文件名称，为空时匹配所有名称
+	Pattern    string
+	Match      MatchMode
+	IgnoreCase bool
+	// MaxDepth 最大搜索深度，1 表示只搜索当前目录
+	MaxDepth int
+	// Type 只返回文件或者目录
+	Type FileType
+	// MinSize MaxSize 文件大小范围，设置后只返回文件
+	MinSize int64
+	MaxSize int64
+	// ModifiedAfter ModifiedBefore 修改时间范围，Unix 时间戳
+	ModifiedAfter  int64
+	ModifiedBefore int64
+	// Limit 最多返回的结果数量
+	Limit int
+}
+
+// matcher 编译后的搜索条件
+type matcher struct {
+	opts  SearchOptions
+	match func(name string) bool
+}
+
+func newMatcher(opts SearchOptions) (*matcher, error) {
+	m := &matcher{opts: opts}
+	pattern := opts.Pattern
+	if opts.IgnoreCase && opts.Match != MatchRegex {
+		pattern = strings.ToLower(pattern)
+	}
+
+	// fold 忽略大小写时统一转换为小写后再匹配
+	fold := func(name string) string {
+		if opts.IgnoreCase {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+
+	switch opts.Match {
+	case "", MatchContains:
+		m.match = func(name string) bool {
+			return strings.Contains(fold(name), pattern)
+		}
+	case MatchGlob:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", opts.Pattern, err)
+		}
+		m.match = func(name string) bool {
+			ok, _ := path.Match(pattern, fold(name))
+			return ok
+		}
+	case MatchRegex:
+		if opts.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", opts.Pattern, err)
+		}
+		m.match = re.MatchString
+	default:
+		return nil, fmt.Errorf("unknown match mode %q", opts.Match)
+	}
+
+	return m, nil
+}
+
+func (m *matcher) accept(file FileInfo) bool {
+	opts := m.opts
+	switch {
+	case opts.Type != "" && file.Type != opts.Type:
+		return false
+	case (opts.MinSize > 0 || opts.MaxSize > 0) && file.Type != FILE:
+		return false
+	case opts.MinSize > 0 && file.FileSize < opts.MinSize:
+		return false
+	case opts.MaxSize > 0 && file.FileSize > opts.MaxSize:
+		return false
+	case opts.ModifiedAfter > 0 && file.LastModified < opts.ModifiedAfter:
+		return false
+	case opts.ModifiedBefore > 0 && file.LastModified > opts.ModifiedBefore:
+		return false
+	}
+
+	return m.match(file.Basename)
+}
+
+// search 从 files 开始逐层搜索子目录，scan 由各个实现提供，用于列出目录下的文件
+// 子目录无法读取时跳过，例如没有权限，达到数量上限或者 ctx 取消后立即返回
+func search(ctx context.Context, files []FileInfo, opts SearchOptions, scan func(dir string) ([]FileInfo, error)) ([]FileInfo, error) {
+	m, err := newMatcher(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, maxDepth := opts.Limit, opts.MaxDepth
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultSearchDepth
+	}
+
+	res := make([]FileInfo, 0)
+	var walk func(files []FileInfo, depth int) error
+	walk = func(files []FileInfo, depth int) error {
+		for _, file := range files {
+			if file.Basename == "." || file.Basename == ".." {
+				continue
+			}
+
+			if m.accept(file) {
+				res = append(res, file)
+				if len(res) >= limit {
+					return errSearchLimit
+				}
+			}
+
+			if file.Type != DIR || depth >= maxDepth {
+				continue
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			children, err := scan(file.Path)
+			if err != nil {
+				slog.Warn("搜索时跳过无法读取的目录", slog.String("path", file.Path), slog.Any("err", err))
+				continue
+			}
+
+			if err = walk(children, depth+1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err = walk(files, 1); err != nil && !errors.Is(err, errSearchLimit) {
+		return nil, err
+	}
+
+	return res, nil
+}
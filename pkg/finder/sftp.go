@@ -1,13 +1,15 @@
 package finder
 
 import (
-	"archive/zip"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ecodeclub/ekit/slice"
 	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
 	"io"
+	"io/fs"
 	"log/slog"
 	"mime"
 	"mime/multipart"
@@ -16,19 +18,49 @@ import (
 	"strings"
 )
 
-type sftpFinder struct {
+// SftpConn 提供 SFTP 客户端，连接断开后由实现方负责重新建立
+type SftpConn interface {
+	SFTP() (*sftp.Client, error)
+}
+
+// SshConn 同时提供 SSH 客户端的连接，可以直接在远程主机上执行命令，例如使用 cp 复制文件
+type SshConn interface {
+	SSH() (*ssh.Client, error)
+}
+
+type staticConn struct {
 	client *sftp.Client
 }
 
+func (c staticConn) SFTP() (*sftp.Client, error) {
+	return c.client, nil
+}
+
+type sftpFinder struct {
+	conn SftpConn
+	// accounts 远程主机的用户以及用户组名称
+	accounts accountCache
+}
+
 func NewSftpFinder(client *sftp.Client) Finder {
+	return NewSftpFinderWithConn(staticConn{client: client})
+}
+
+// NewSftpFinderWithConn 每次请求都从 conn 获取客户端，连接断开重连后自动恢复
+func NewSftpFinderWithConn(conn SftpConn) Finder {
 	return &sftpFinder{
-		client: client,
+		conn: conn,
 	}
 }
 
 func (sf *sftpFinder) Save(ctx context.Context, path, content string) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
 	// 使用 os.O_WRONLY|os.O_TRUNC 来覆盖文件内容
-	file, err := sf.client.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE)
+	file, err := client.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE)
 	if err != nil {
 		return err
 	}
@@ -43,6 +75,29 @@ func (sf *sftpFinder) Save(ctx context.Context, path, content string) error {
 	return nil
 }
 
+func (sf *sftpFinder) Put(ctx context.Context, path string, content io.Reader, size int64) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	if err = client.MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	file, err := client.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, content); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
 func (sf *sftpFinder) Subfolders(ctx context.Context, adapter, path string) ([]FileInfo, error) {
 	if strings.Contains(path, "://") {
 		split := strings.Split(path, "://")
@@ -69,64 +124,127 @@ func (sf *sftpFinder) Subfolders(ctx context.Context, adapter, path string) ([]F
 	return files, nil
 }
 
-func (sf *sftpFinder) Preview(ctx context.Context, path string) (bytes.Buffer, error) {
-	var buff bytes.Buffer
-	file, err := sf.client.Open(path)
-	if err != nil {
-		return buff, err
-	}
-
-	if _, err = file.WriteTo(&buff); err != nil {
-		return buff, err
-	}
-
-	return buff, nil
+func (sf *sftpFinder) Preview(ctx context.Context, path string) (Content, error) {
+	return sf.open(path)
 }
 
-func (sf *sftpFinder) Search(ctx context.Context, adapter, path, filter string) (Storages, error) {
+// Search 从当前目录开始逐层搜索，当前目录的列表复用 Index 的结果
+func (sf *sftpFinder) Search(ctx context.Context, adapter, path string, opts SearchOptions) (Storages, error) {
 	storage, err := sf.Index(ctx, adapter, path)
 	if err != nil {
 		return Storages{}, err
 	}
 
-	storage.Files = slice.FilterMap(storage.Files, func(idx int, src FileInfo) (FileInfo, bool) {
-		if strings.Contains(src.Basename, filter) {
-			return src, true
-		}
-		return FileInfo{}, false
+	storage.Files, err = search(ctx, storage.Files, opts, func(dir string) ([]FileInfo, error) {
+		return sf.scan(dir, storage.Adapter)
 	})
+	if err != nil {
+		return Storages{}, err
+	}
 
 	return storage, nil
 }
 
-func ensureZipExtension(target string) string {
-	// 检查目标路径的扩展名是否为 .zip
-	if !strings.HasSuffix(target, ".zip") {
-		// 如果没有 .zip 后缀，则添加 .zip
-		target = target + ".zip"
+func (sf *sftpFinder) Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
 	}
-	return target
-}
 
-func (sf *sftpFinder) Archive(ctx context.Context, items []Item, target, base string) error {
 	// 判断是否有后缀，如果没有自行添加上
-	zipFileName := ensureZipExtension(ensureZipExtension(target))
+	name, format, err := archiveName(filepath.Join(base, target), format)
+	if err != nil {
+		return err
+	}
+
+	file, err := client.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if err = pack(ctx, sftpSource{client: client}, items, base, file, format); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// sftpSource 打包远程主机上的文件，内容通过 SFTP 按需读取
+type sftpSource struct {
+	client *sftp.Client
+}
+
+func (s sftpSource) Lstat(path string) (fs.FileInfo, error) {
+	return s.client.Lstat(path)
+}
+
+func (s sftpSource) Stat(path string) (fs.FileInfo, error) {
+	return s.client.Stat(path)
+}
+
+func (s sftpSource) ReadLink(path string) (string, error) {
+	return s.client.ReadLink(path)
+}
+
+func (s sftpSource) ReadDir(path string) ([]fs.FileInfo, error) {
+	return s.client.ReadDir(path)
+}
+
+func (s sftpSource) Open(path string) (io.ReadCloser, error) {
+	return s.client.Open(path)
+}
+
+func (sf *sftpFinder) Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
 
-	zipFile, err := sf.client.Create(zipFileName)
+	content, err := sf.open(item)
 	if err != nil {
 		return err
 	}
-	defer zipFile.Close()
+	defer content.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	return unarchive(ctx, content, target, policy, sftpTarget{client: client})
+}
+
+// sftpTarget 解压到远程主机，压缩包内容通过 SFTP 按需读取，不经过本地磁盘
+type sftpTarget struct {
+	client *sftp.Client
+}
+
+func (t sftpTarget) Lstat(path string) (fs.FileInfo, error) {
+	return t.client.Lstat(path)
+}
+
+func (t sftpTarget) MkdirAll(path string) error {
+	return t.client.MkdirAll(path)
+}
+
+func (t sftpTarget) Create(path string) (io.WriteCloser, error) {
+	return t.client.Create(path)
+}
 
+func (t sftpTarget) RemoveAll(path string) error {
+	return removeAll(t.client, path)
+}
+
+func (t sftpTarget) Symlink(link, path string) error {
+	return t.client.Symlink(link, path)
+}
+
+func (t sftpTarget) Chmod(path string, mode fs.FileMode) error {
+	return t.client.Chmod(path, mode)
+}
+
+func (sf *sftpFinder) Move(ctx context.Context, items []Item, target string) error {
 	for _, item := range items {
-		if blockOperation("archive", base, item.Path) {
-			continue
-		}
+		fileName := filepath.Base(item.Path)
+		destPath := filepath.Join(target, fileName)
 
-		err = sf.walkAndZip(item.Path, zipWriter, base)
+		err := sf.rename(item.Path, destPath)
 		if err != nil {
 			return err
 		}
@@ -135,77 +253,186 @@ func (sf *sftpFinder) Archive(ctx context.Context, items []Item, target, base st
 	return nil
 }
 
-func (sf *sftpFinder) walkAndZip(path string, zipWriter *zip.Writer, basePath string) error {
-	info, err := sf.client.Stat(path)
+// Copy 优先通过 SSH 在远程主机上执行 cp，不支持执行命令时通过 SFTP 读取后写入
+func (sf *sftpFinder) Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error {
+	client, err := sf.conn.SFTP()
 	if err != nil {
 		return err
 	}
 
-	header, err := zip.FileInfoHeader(info)
+	src, dst := sftpSource{client: client}, sftpTarget{client: client}
+	return copyItems(ctx, src, dst, items, target, policy, func(from, to string, info fs.FileInfo, merge bool) error {
+		err := sf.remoteCopy(ctx, client, from, to, merge)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+
+		return copyTree(ctx, src, dst, from, to, info, merge)
+	})
+}
+
+// newSession 打开执行命令的 SSH 会话，连接不支持或者无法打开会话时返回 errors.ErrUnsupported
+func (sf *sftpFinder) newSession() (*ssh.Session, error) {
+	conn, ok := sf.conn.(SshConn)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	sshClient, err := conn.SSH()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 去掉公共前缀
-	relativePath := strings.TrimPrefix(path, basePath)
-	header.Name = relativePath
-	if info.IsDir() {
-		header.Name += "/"
-		header.Method = zip.Store
-	} else {
-		header.Method = zip.Deflate
+	session, err := sshClient.NewSession()
+	if err != nil {
+		slog.Warn("无法打开 SSH 会话", slog.Any("err", err))
+		return nil, errors.ErrUnsupported
 	}
 
-	writer, err := zipWriter.CreateHeader(header)
+	return session, nil
+}
+
+// remoteCopy 无法在远程主机上执行 cp 时返回 errors.ErrUnsupported，例如只允许 SFTP 的账号
+func (sf *sftpFinder) remoteCopy(ctx context.Context, client *sftp.Client, from, to string, merge bool) error {
+	session, err := sf.newSession()
 	if err != nil {
 		return err
 	}
+	defer session.Close()
 
-	if !info.IsDir() {
-		var remoteFile *sftp.File
-		remoteFile, err = sf.client.Open(path)
-		if err != nil {
-			return err
-		}
-		defer remoteFile.Close()
+	// 合并目录时复制目录下的内容，否则 cp 会复制到已存在目录的子目录中
+	if merge {
+		from += "/."
+	}
 
-		_, err = io.Copy(writer, remoteFile)
-		if err != nil {
-			return err
-		}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(fmt.Sprintf("cp -R -- %s %s", shellQuote(from), shellQuote(to)))
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		return ctx.Err()
+	case err = <-done:
 	}
 
-	if info.IsDir() {
-		var files []os.FileInfo
-		files, err = sf.client.ReadDir(path)
-		if err != nil {
-			return err
-		}
+	var exitErr *ssh.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitStatus() != 127:
+		return fmt.Errorf("cp %s %s: %w: %s", from, to, err, strings.TrimSpace(stderr.String()))
+	case err != nil:
+		slog.Warn("远程执行 cp 失败，使用 SFTP 复制", slog.Any("err", err), slog.String("stderr", stderr.String()))
+		return errors.ErrUnsupported
+	}
 
-		for _, file := range files {
-			subPath := filepath.Join(path, file.Name())
-			err = sf.walkAndZip(subPath, zipWriter, basePath)
-			if err != nil {
-				return err
-			}
-		}
+	// 部分服务端强制执行 internal-sftp，命令没有真正执行但同样正常退出
+	if _, err = client.Lstat(to); errors.Is(err, fs.ErrNotExist) {
+		return errors.ErrUnsupported
 	}
 
-	return nil
+	return err
 }
 
-func (sf *sftpFinder) Move(ctx context.Context, items []Item, target string) error {
-	for _, item := range items {
-		fileName := filepath.Base(item.Path)
-		destPath := filepath.Join(target, fileName)
+// Grep 优先在远程主机上执行 grep，无法执行命令时通过 SFTP 逐个读取文件
+// path 必须是绝对路径，以 - 开头的相对路径会被 find 当作表达式，例如 -delete
+func (sf *sftpFinder) Grep(ctx context.Context, path string, opts GrepOptions) ([]GrepMatch, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, pathError("grep", path, fs.ErrInvalid)
+	}
 
-		err := sf.client.Rename(item.Path, destPath)
-		if err != nil {
-			return err
-		}
+	if _, err := opts.matcher(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	matches, err := sf.remoteGrep(ctx, path, opts)
+	if !errors.Is(err, errors.ErrUnsupported) {
+		return matches, err
+	}
+
+	scan := func(dir string) ([]FileInfo, error) {
+		return sf.scan(dir, "")
+	}
+
+	return grepFiles(ctx, path, opts, scan, sf.open)
+}
+
+// remoteGrep 边读取输出边解析，达到数量上限后直接关闭会话结束远程命令
+func (sf *sftpFinder) remoteGrep(ctx context.Context, root string, opts GrepOptions) ([]GrepMatch, error) {
+	session, err := sf.newSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if err = session.Start(grepCommand(root, opts)); err != nil {
+		slog.Warn("远程执行 grep 失败，使用 SFTP 搜索", slog.Any("err", err))
+		return nil, errors.ErrUnsupported
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+	})
+	defer stop()
+
+	matches, done, err := parseGrep(stdout, opts.limit())
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err != nil:
+		return nil, err
+	case len(matches) >= opts.limit():
+		return matches, nil
+	}
+
+	_ = session.Wait()
+
+	// 没有结束标记说明命令没有真正执行，例如强制 internal-sftp 的账号
+	// 没有结果但有错误输出时可能是 grep 不支持相关参数，同样改为逐个读取
+	if !done || len(matches) == 0 && stderr.Len() > 0 {
+		slog.Warn("远程执行 grep 失败，使用 SFTP 搜索", slog.String("stderr", stderr.String()))
+		return nil, errors.ErrUnsupported
+	}
+
+	return matches, nil
+}
+
+// shellQuote 使用单引号包裹参数，避免路径中的特殊字符被 shell 解析
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (sf *sftpFinder) Chmod(ctx context.Context, items []Item, mode string, recursive bool) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	return chmodTree(ctx, sftpSource{client: client}, items, mode, recursive, client.Chmod)
+}
+
+// Chown 名称通过远程主机的 /etc/passwd 以及 /etc/group 解析
+func (sf *sftpFinder) Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	names := sf.accounts.get(func(name string) (io.ReadCloser, error) {
+		return client.Open(name)
+	})
+
+	return chownTree(ctx, sftpSource{client: client}, items, owner, group, recursive, names, client.Chown)
 }
 
 func (sf *sftpFinder) Remove(ctx context.Context, items []Item, path string) error {
@@ -232,20 +459,90 @@ func (sf *sftpFinder) Remove(ctx context.Context, items []Item, path string) err
 }
 
 func (sf *sftpFinder) RemoveDir(ctx context.Context, file string) error {
-	return sf.client.RemoveAll(file)
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	return removeAll(client, file)
+}
+
+// removeAll sftp.Client.RemoveAll 会跟随软链接删除目标目录下的内容，这里只删除软链接本身
+func removeAll(client *sftp.Client, path string) error {
+	info, err := client.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		files, err := client.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err = removeAll(client, filepath.Join(path, file.Name())); err != nil {
+				return err
+			}
+		}
+
+		return client.RemoveDirectory(path)
+	}
+
+	return client.Remove(path)
 }
 
 func (sf *sftpFinder) RemoveFile(ctx context.Context, file string) error {
-	return sf.client.Remove(file)
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	return client.Remove(file)
 }
 
 func (sf *sftpFinder) Rename(ctx context.Context, oldPathName, newName, path string) error {
-	newPath := replaceLastPart(oldPathName, newName)
-	if blockOperation("rename", oldPathName, newPath) {
+	if blockOperation("rename", path, oldPathName) {
 		return nil
 	}
+	if err := checkName("rename", newName); err != nil {
+		return err
+	}
+
+	return sf.rename(oldPathName, replaceLastPart(oldPathName, newName))
+}
+
+// realPath 不使用 SFTP 的 realpath 请求，部分服务端只做字面处理，不会解析软链接
+func (sf *sftpFinder) realPath(path string) (string, error) {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return "", err
+	}
 
-	return sf.client.Rename(oldPathName, newPath)
+	return evalSymlinks(sftpSource{client: client}, path)
+}
+
+// rename 不同 SFTP 服务端对目标已存在的处理不一致，统一拒绝覆盖
+func (sf *sftpFinder) rename(oldPath, newPath string) error {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Lstat(newPath); err == nil {
+		return pathError("rename", newPath, fs.ErrExist)
+	}
+
+	return client.Rename(oldPath, newPath)
+}
+
+// checkName 重命名只修改最后一级名称，包含分隔符或者为 . 以及 .. 时会改变所在的目录
+func checkName(op, name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return pathError(op, name, fs.ErrInvalid)
+	}
+
+	return nil
 }
 
 func replaceLastPart(originalPath, newName string) string {
@@ -258,59 +555,91 @@ func replaceLastPart(originalPath, newName string) string {
 }
 
 func (sf *sftpFinder) NewFolder(ctx context.Context, file string, name string) error {
-	return sf.client.MkdirAll(fmt.Sprintf("/%s/%s", file, name))
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	return client.MkdirAll(filepath.Join(file, name))
 }
 
 func (sf *sftpFinder) NewFile(ctx context.Context, file string, name string) error {
-	_, err := sf.client.Create(fmt.Sprintf("/%s/%s", file, name))
-	return err
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
+	}
+
+	f, err := client.Create(filepath.Join(file, name))
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
 }
 
-func (sf *sftpFinder) Download(ctx context.Context, filePath string) (bytes.Buffer, error) {
-	var buff bytes.Buffer
-	file, err := sf.client.Open(filePath)
+func (sf *sftpFinder) Download(ctx context.Context, filePath string) (Content, error) {
+	return sf.open(filePath)
+}
+
+// open 打开远程文件，按需从远端读取内容，避免整个文件加载到内存
+func (sf *sftpFinder) open(path string) (Content, error) {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return Content{}, err
+	}
+
+	file, err := client.Open(path)
+	if err != nil {
+		return Content{}, err
+	}
+
+	info, err := file.Stat()
 	if err != nil {
-		return buff, err
+		file.Close()
+		return Content{}, err
 	}
 
-	if _, err = file.WriteTo(&buff); err != nil {
-		return buff, err
+	if info.IsDir() {
+		file.Close()
+		return Content{}, pathError("read", path, fs.ErrInvalid)
 	}
 
-	return buff, nil
+	return Content{
+		ReadSeekCloser: file,
+		Name:           info.Name(),
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+	}, nil
 }
 
 func (sf *sftpFinder) Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error {
-	// 如果 remoteFile 包含 "/"，则需要解析出目录和文件名
-	if strings.Contains(remoteFile, "/") {
-		parts := strings.Split(remoteFile, "/")
-		// 最后一个部分是文件名
-		remoteFile = parts[len(parts)-1]
-		// 前面的部分是目录
-		remoteDir = fmt.Sprintf("%s/%s", remoteDir, strings.Join(parts[:len(parts)-1], "/"))
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return err
 	}
 
-	remoteFile = fmt.Sprintf("%s/%s", remoteDir, remoteFile)
+	// 如果 remoteFile 包含 "/"，则需要解析出目录和文件名
+	remoteDir, remoteFile = parseFilePath(remoteDir, remoteFile)
 
-	if _, err := sf.client.Stat(remoteDir); os.IsNotExist(err) {
-		if err = sf.client.MkdirAll(remoteDir); err != nil {
+	if _, err := client.Stat(remoteDir); os.IsNotExist(err) {
+		if err = client.MkdirAll(remoteDir); err != nil {
 			return err
 		}
 	}
 
 	// 打开源文件
 	srcFile, err := src.Open()
-	defer srcFile.Close()
 	if err != nil {
 		return err
 	}
+	defer srcFile.Close()
 
 	// 创建并打开目标文件
-	dstFile, err := sf.client.Create(remoteFile)
-	defer dstFile.Close()
+	dstFile, err := client.Create(remoteFile)
 	if err != nil {
 		return err
 	}
+	defer dstFile.Close()
 
 	// 缓冲区读取并写入 4MB
 	buffer := make([]byte, 4*1024*1024)
@@ -353,8 +682,13 @@ func parseFilePath(remoteDir, remoteFile string) (string, string) {
 }
 
 func (sf *sftpFinder) getRemoteFileSize(remoteFile string) (int64, error) {
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return 0, err
+	}
+
 	// 尝试获取远程文件的 stat 信息
-	fileInfo, err := sf.client.Stat(remoteFile)
+	fileInfo, err := client.Stat(remoteFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return 0, nil
@@ -387,8 +721,14 @@ func (sf *sftpFinder) Index(ctx context.Context, adapter, path string) (Storages
 		}
 
 	} else {
-		var pwd string
-		if pwd, err = sf.client.Getwd(); err != nil {
+		var (
+			client *sftp.Client
+			pwd    string
+		)
+		if client, err = sf.conn.SFTP(); err != nil {
+			return Storages{}, err
+		}
+		if pwd, err = client.Getwd(); err != nil {
 			return Storages{}, err
 		}
 		newAdapter = getFirstPathPart(pwd)
@@ -417,7 +757,12 @@ func getFirstPathPart(path string) string {
 }
 
 func (sf *sftpFinder) findStorage() ([]string, error) {
-	fileInfos, err := sf.client.ReadDir("/")
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfos, err := client.ReadDir("/")
 	if err != nil {
 		return nil, err
 	}
@@ -437,43 +782,49 @@ func (sf *sftpFinder) findStorage() ([]string, error) {
 
 // ScanFiles 查找指定路径下所有文件
 func (sf *sftpFinder) scan(path, adapter string) ([]FileInfo, error) {
-	files, err := sf.client.ReadDir(path)
+	client, err := sf.conn.SFTP()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := client.ReadDir(path)
 	if err != nil {
 		return nil, err
 	}
 
 	fileInfos := make([]FileInfo, 0)
+	names := sf.accounts.get(func(name string) (io.ReadCloser, error) {
+		return client.Open(name)
+	})
 
 	for _, file := range files {
-		f := convertToFileInfo(file, path, adapter)
+		f := names.fill(convertToFileInfo(file, path, adapter))
+		if file.Mode()&os.ModeSymlink != 0 {
+			f = resolveLink(f, sftpSource{client: client})
+		}
 		fileInfos = append(fileInfos, f)
 	}
 
 	return fileInfos, nil
 }
 
-// convertToFileInfo 文件转换为finder前端识别
+// convertToFileInfo 文件转换为finder前端识别，软链接需要再通过 resolveLink 解析目标类型
+// 属主以及属组只填充数字 id，名称由 accounts 填充
 func convertToFileInfo(file os.FileInfo, path, adapter string) FileInfo {
 	ext := strings.TrimPrefix(filepath.Ext(file.Name()), ".")
 	mimeType := mime.TypeByExtension("." + ext)
 
 	// 构建 FileInfo 结构体
-	return FileInfo{
+	info := FileInfo{
 		Type: func() FileType {
 			if file.IsDir() {
 				return DIR
-			} else if file.Mode()&os.ModeSymlink != 0 {
-				fileType, err := getLinkType(file, adapter)
-				if err != nil {
-					slog.Error("Failed to get link type", file.Name(), slog.Any("err", err))
-				}
-				return fileType
 			} else {
 				return FILE
 			}
 		}(),
-		Path:          fmt.Sprintf("%s/%s", path, file.Name()),
-		Visibility:    "public",
+		Path:          filepath.Join(path, file.Name()),
+		Visibility:    visibility(file.Mode()),
 		LastModified:  file.ModTime().Unix(),
 		MimeType:      mimeType,
 		ExtraMetadata: []string{},
@@ -481,29 +832,44 @@ func convertToFileInfo(file os.FileInfo, path, adapter string) FileInfo {
 		Extension:     ext,
 		Storage:       adapter,
 		FileSize:      file.Size(),
+		Mode:          file.Mode().String(),
+		Perm:          octal(file.Mode()),
+	}
+
+	if uid, gid, ok := fileOwner(file); ok {
+		info.Uid, info.Gid = &uid, &gid
 	}
+
+	return info
+}
+
+// linkResolver 在文件所在的存储上解析软链接，SFTP 需要在远程主机上解析
+type linkResolver interface {
+	ReadLink(path string) (string, error)
+	Stat(path string) (fs.FileInfo, error)
 }
 
-// getLinkType 获取软链接的目标类型
-func getLinkType(file os.FileInfo, path string) (FileType, error) {
-	// 读取目标文件
-	targetPath, err := os.Readlink(filepath.Join(path, file.Name()))
+// resolveLink 软链接按照目标的类型展示，指向目录时可以直接进入，目标不存在或者无法访问时标记为失效
+func resolveLink(file FileInfo, links linkResolver) FileInfo {
+	target, err := links.ReadLink(file.Path)
 	if err != nil {
-		return "", err
+		slog.Warn("读取软链接失败", slog.String("path", file.Path), slog.Any("err", err))
 	}
+	file.LinkTarget = target
 
-	// 获取目标的文件信息
-	targetInfo, err := os.Stat(targetPath)
+	info, err := links.Stat(file.Path)
 	if err != nil {
-		return "", err
+		file.BrokenLink = true
+		return file
 	}
 
-	// 判断是否为目录
-	if targetInfo.IsDir() {
-		return DIR, nil
+	if info.IsDir() {
+		file.Type = DIR
 	} else {
-		return FILE, nil
+		file.FileSize = info.Size()
 	}
+
+	return file
 }
 
 func (sf *sftpFinder) scanFiles(path, adapter string) ([]FileInfo, error) {
@@ -550,12 +916,17 @@ func matchPath(path string) bool {
 	return count != 1
 }
 
-func blockOperation(action string, oldFile, newFile string) bool {
-	// 根据路径长度，避免删除或修改 .. 上级目录 这种情况
-	if len(oldFile) > len(newFile) {
-		slog.Error("发现触发危险操作, 被系统阻止", slog.String("当前", oldFile), slog.String("处理", newFile), slog.String("动作", action))
+// blockOperation 只允许操作当前目录下的文件，避免删除或修改 . 以及 .. 上级目录 这种情况
+func blockOperation(action string, current, target string) bool {
+	dir := strings.TrimSuffix(filepath.Clean("/"+current), "/") + "/"
+	if !strings.HasPrefix(filepath.Clean("/"+target), dir) {
+		slog.Error("发现触发危险操作, 被系统阻止", slog.String("当前", current), slog.String("处理", target), slog.String("动作", action))
 		return true
 	}
 
 	return false
 }
+
+func pathError(op, path string, err error) error {
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
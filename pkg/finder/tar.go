@@ -0,0 +1,168 @@
+package finder
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/sftp"
+	"io"
+	"io/fs"
+)
+
+type tarWriter struct {
+	*tar.Writer
+	// compressor 压缩层，tar 格式为空
+	compressor io.WriteCloser
+}
+
+func newTarWriter(w io.Writer, format ArchiveFormat) (archiveWriter, error) {
+	var compressor io.WriteCloser
+	switch format {
+	case FormatTar:
+	case FormatTarGz:
+		compressor = gzip.NewWriter(w)
+	case FormatTarZst:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		compressor = encoder
+	default:
+		return nil, pathError("archive", string(format), ErrUnknownFormat)
+	}
+
+	if compressor != nil {
+		w = compressor
+	}
+
+	return &tarWriter{Writer: tar.NewWriter(w), compressor: compressor}, nil
+}
+
+func (w *tarWriter) Add(name string, info fs.FileInfo, link string) (io.Writer, error) {
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	// 本地文件由 FileInfoHeader 读取属主，SFTP 需要从文件属性中获取
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		header.Uid, header.Gid = int(stat.UID), int(stat.GID)
+	}
+
+	if err = w.WriteHeader(header); err != nil {
+		return nil, err
+	}
+
+	return w.Writer, nil
+}
+
+func (w *tarWriter) KeepLinks() bool {
+	return true
+}
+
+func (w *tarWriter) Close() error {
+	err := w.Writer.Close()
+	if w.compressor != nil {
+		err = errors.Join(err, w.compressor.Close())
+	}
+
+	return err
+}
+
+// newTarReader 解压缩层，返回的 close 用于释放解压器
+func newTarReader(r io.Reader, format ArchiveFormat) (*tar.Reader, func(), error) {
+	switch format {
+	case FormatTar:
+		return tar.NewReader(r), func() {}, nil
+	case FormatTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gz), func() { _ = gz.Close() }, nil
+	case FormatTarZst:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(decoder), decoder.Close, nil
+	default:
+		return nil, nil, pathError("unarchive", string(format), ErrUnknownFormat)
+	}
+}
+
+// untar tar 只能顺序读取，第一遍读取条目头校验，回到开头后再写入文件
+func (e *extractor) untar(content Content, format ArchiveFormat) error {
+	e.keepMode = true
+
+	var entries []archiveEntry
+	err := readTar(content, format, func(header *tar.Header, r io.Reader) error {
+		entries = append(entries, tarEntry(header))
+		return e.ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = e.check(entries); err != nil {
+		return err
+	}
+
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err = e.dst.MkdirAll(e.target); err != nil {
+		return err
+	}
+
+	err = readTar(content, format, func(header *tar.Header, r io.Reader) error {
+		return e.write(tarEntry(header), r)
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.finish()
+}
+
+func readTar(r io.Reader, format ArchiveFormat, fn func(header *tar.Header, r io.Reader) error) error {
+	reader, closeFn, err := newTarReader(r, format)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = fn(header, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// tarEntry 硬链接以及设备文件等类型的模式不是目录、普通文件或软链接，解压时会被跳过
+func tarEntry(header *tar.Header) archiveEntry {
+	entry := archiveEntry{name: header.Name, mode: header.FileInfo().Mode()}
+	if header.Typeflag == tar.TypeSymlink {
+		entry.link = header.Linkname
+	}
+	if header.Typeflag == tar.TypeLink {
+		entry.mode = fs.ModeIrregular
+	}
+
+	return entry
+}
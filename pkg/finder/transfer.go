@@ -0,0 +1,161 @@
+package finder
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// Transfer 在任意两个 Finder 之间复制文件，move 为 true 时复制完成后删除源文件
+// 文件内容边读取边写入，不会在服务端缓存整个文件，冲突策略与 Copy 一致
+func Transfer(ctx context.Context, src, dst Finder, items []Item, target string, policy ConflictPolicy, move bool) error {
+	target = path.Clean(target)
+	files, err := listDir(ctx, dst, target)
+	if err != nil {
+		return err
+	}
+
+	existing := finderTarget{ctx: ctx, f: dst, files: make(map[string]FileInfo, len(files))}
+	for _, file := range files {
+		existing.files[path.Clean(file.Path)] = file
+	}
+
+	for _, item := range items {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		from := path.Clean(item.Path)
+		to, _, err := copyDest(existing, path.Join(target, path.Base(from)), itemStat(item), policy)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case to == "":
+			continue
+		case src == dst && (to == from || item.Type == DIR && within(from, to)):
+			return pathError("transfer", to, fs.ErrInvalid)
+		}
+
+		if err = transferItem(ctx, src, dst, Item{Path: from, Type: item.Type}, to); err != nil {
+			return err
+		}
+		existing.files[to] = FileInfo{Type: item.Type, Path: to, Basename: path.Base(to)}
+
+		if !move {
+			continue
+		}
+
+		if item.Type == DIR {
+			err = src.RemoveDir(ctx, from)
+		} else {
+			err = src.RemoveFile(ctx, from)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transferItem 目录逐层列出后创建，已存在的目录直接合并，不进入目录中的软链接
+func transferItem(ctx context.Context, src, dst Finder, item Item, to string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if item.Type != DIR {
+		content, err := src.Download(ctx, item.Path)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		return dst.Put(ctx, to, content, content.Size)
+	}
+
+	if err := dst.NewFolder(ctx, to, ""); err != nil {
+		return err
+	}
+
+	files, err := listDir(ctx, src, item.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		// 软链接可能指向自身的上级目录形成循环，或者指向源目录以外，目标不一定支持软链接，直接跳过
+		if file.LinkTarget != "" || file.BrokenLink {
+			slog.Warn("跳过目录中的软链接", slog.String("path", file.Path), slog.String("target", file.LinkTarget))
+			continue
+		}
+
+		err = transferItem(ctx, src, dst, Item{Path: file.Path, Type: file.Type}, path.Join(to, file.Basename))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listDir 通过 Index 列出目录，去掉 . 以及 .. 两个导航条目
+func listDir(ctx context.Context, f Finder, dir string) ([]FileInfo, error) {
+	adapter, _, _ := strings.Cut(strings.TrimPrefix(dir, "/"), "/")
+	storage, err := f.Index(ctx, adapter, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(storage.Files))
+	for _, file := range storage.Files {
+		if file.Basename == "." || file.Basename == ".." {
+			continue
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func itemStat(item Item) fs.FileInfo {
+	if item.Type == DIR {
+		return fileStat{name: path.Base(item.Path), mode: fs.ModeDir | 0755}
+	}
+
+	return fileStat{name: path.Base(item.Path), mode: 0644}
+}
+
+// finderTarget 根据目标目录的列表判断冲突，只能查询目标目录下的直接子条目
+type finderTarget struct {
+	ctx   context.Context
+	f     Finder
+	files map[string]FileInfo
+}
+
+func (t finderTarget) Lstat(p string) (fs.FileInfo, error) {
+	file, ok := t.files[p]
+	if !ok {
+		return nil, pathError("stat", p, fs.ErrNotExist)
+	}
+
+	return itemStat(Item{Path: file.Path, Type: file.Type}), nil
+}
+
+func (t finderTarget) RemoveAll(p string) error {
+	file, ok := t.files[p]
+	if !ok {
+		return nil
+	}
+	delete(t.files, p)
+
+	if file.Type == DIR {
+		return t.f.RemoveDir(t.ctx, p)
+	}
+
+	return t.f.RemoveFile(t.ctx, p)
+}
@@ -1,9 +1,10 @@
 package finder
 
 import (
-	"bytes"
 	"context"
+	"io"
 	"mime/multipart"
+	"time"
 )
 
 type FileType string
@@ -18,19 +19,29 @@ const (
 type Finder interface {
 	Index(ctx context.Context, adapter, path string) (Storages, error)
 	Upload(ctx context.Context, src *multipart.FileHeader, remoteDir, remoteFile string) error
-	Download(ctx context.Context, filePath string) (bytes.Buffer, error)
+	Download(ctx context.Context, filePath string) (Content, error)
 	Rename(ctx context.Context, oldPathName, newName, path string) error
 	NewFolder(ctx context.Context, file, name string) error
 	NewFile(ctx context.Context, file, name string) error
 	Remove(ctx context.Context, items []Item, path string) error
 	RemoveDir(ctx context.Context, file string) error
 	RemoveFile(ctx context.Context, file string) error
-	Archive(ctx context.Context, items []Item, target, base string) error
+	Archive(ctx context.Context, items []Item, target, base string, format ArchiveFormat) error
+	Unarchive(ctx context.Context, item, target string, policy ConflictPolicy) error
 	Move(ctx context.Context, items []Item, target string) error
-	Preview(ctx context.Context, path string) (bytes.Buffer, error)
-	Search(ctx context.Context, adapter, path, filter string) (Storages, error)
+	Copy(ctx context.Context, items []Item, target string, policy ConflictPolicy) error
+	// Chmod 修改权限，mode 支持八进制例如 0755 以及符号模式例如 u+x,go-w
+	Chmod(ctx context.Context, items []Item, mode string, recursive bool) error
+	// Chown 修改属主以及属组，支持名称以及数字 id，为空时保持不变
+	Chown(ctx context.Context, items []Item, owner, group string, recursive bool) error
+	Preview(ctx context.Context, path string) (Content, error)
+	Search(ctx context.Context, adapter, path string, opts SearchOptions) (Storages, error)
+	// Grep 递归搜索 path 下文件内容中匹配的行
+	Grep(ctx context.Context, path string, opts GrepOptions) ([]GrepMatch, error)
 	Subfolders(ctx context.Context, adapter, path string) ([]FileInfo, error)
 	Save(ctx context.Context, path, content string) error
+	// Put 流式写入文件内容，父目录不存在时自动创建，size 未知时为 -1
+	Put(ctx context.Context, path string, content io.Reader, size int64) error
 }
 
 type Storages struct {
@@ -38,6 +49,8 @@ type Storages struct {
 	Storages []string   `json:"storages"`
 	Dirname  string     `json:"dirname"`
 	Files    []FileInfo `json:"files"`
+	// Pagination 指定排序或者分页条件时返回
+	Pagination *Pagination `json:"pagination,omitempty"`
 }
 
 type FileInfo struct {
@@ -51,6 +64,27 @@ type FileInfo struct {
 	Extension     string   `json:"extension"`
 	Storage       string   `json:"storage"`
 	FileSize      int64    `json:"file_size"`
+	// Mode 类型以及权限位，格式与 ls -l 一致，例如 drwxr-xr-x，Perm 为八进制表示
+	Mode string `json:"mode,omitempty"`
+	Perm string `json:"perm,omitempty"`
+	// Uid Gid 属主以及属组的数字 id，存储不支持时为空，Owner Group 为对应的名称
+	Uid   *uint32 `json:"uid,omitempty"`
+	Gid   *uint32 `json:"gid,omitempty"`
+	Owner string  `json:"owner,omitempty"`
+	Group string  `json:"group,omitempty"`
+	// LinkTarget 软链接指向的路径，Type 以及 FileSize 取自链接的目标
+	LinkTarget string `json:"link_target,omitempty"`
+	// BrokenLink 软链接的目标不存在或者无法访问
+	BrokenLink bool `json:"broken_link,omitempty"`
+}
+
+// Content 文件内容流以及元信息，调用方读取完成后需要关闭
+// 支持 Seek，用于断点续传等只读取部分内容的场景
+type Content struct {
+	io.ReadSeekCloser
+	Name    string
+	Size    int64
+	ModTime time.Time
 }
 
 type Item struct {
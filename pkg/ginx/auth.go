@@ -0,0 +1,196 @@
+package ginx
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const principalKey = "ginx.principal"
+
+var (
+	// ErrNoCredentials 请求没有携带当前认证方式的凭证，继续尝试下一种认证方式
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials 凭证无效，例如密码错误、签名错误或者已过期
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal 认证通过的调用方
+type Principal struct {
+	// Name JWT 取自 sub 声明，API Token 取自配置中的名称，HTTP Basic 为用户名
+	Name string `json:"name"`
+	// Method 认证方式，可选 jwt、token、basic
+	Method string `json:"method"`
+	// Groups JWT 中 groups 声明的用户组
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Authenticator 一种认证方式
+type Authenticator interface {
+	// Authenticate 没有携带对应凭证时返回 ErrNoCredentials
+	Authenticate(r *http.Request) (Principal, error)
+	// Challenge 认证失败时返回的 WWW-Authenticate 头
+	Challenge() string
+}
+
+// AuthConfig 认证配置，至少需要配置一种认证方式
+type AuthConfig struct {
+	// Realm HTTP Basic 认证的 realm，默认 vuefinder
+	Realm string     `json:"realm"`
+	JWT   *JWTConfig `json:"jwt"`
+	// Tokens 静态 API Token，名称到 Token 的映射，名称作为 Principal 的 Name
+	Tokens map[string]string `json:"tokens"`
+	// Htpasswd HTTP Basic 认证使用的 htpasswd 文件，支持 bcrypt、apr1 以及 SHA1
+	Htpasswd string `json:"htpasswd"`
+}
+
+// LoadAuthConfig 从 JSON 文件加载认证配置
+func LoadAuthConfig(file string) (AuthConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
+	var cfg AuthConfig
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return AuthConfig{}, fmt.Errorf("parse auth config %s: %w", file, err)
+	}
+
+	return cfg, nil
+}
+
+// NewAuthMiddleware 按照 API Token、JWT、HTTP Basic 的顺序尝试配置的认证方式
+func NewAuthMiddleware(cfg AuthConfig) (gin.HandlerFunc, error) {
+	var authenticators []Authenticator
+	if len(cfg.Tokens) > 0 {
+		authenticators = append(authenticators, NewTokenAuthenticator(cfg.Tokens))
+	}
+
+	if cfg.JWT != nil {
+		jwtAuth, err := NewJWTAuthenticator(*cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuth)
+	}
+
+	if cfg.Htpasswd != "" {
+		realm := cfg.Realm
+		if realm == "" {
+			realm = "vuefinder"
+		}
+		basic, err := NewBasicAuthenticator(cfg.Htpasswd, realm)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, basic)
+	}
+
+	if len(authenticators) == 0 {
+		return nil, errors.New("auth config contains no authentication method")
+	}
+
+	return Authenticate(authenticators...), nil
+}
+
+// Authenticate 依次尝试 authenticators，第一个认证通过的调用方可以通过 GetPrincipal 获取
+// 所有认证方式都没有找到凭证，或者凭证无效时返回 401
+func Authenticate(authenticators ...Authenticator) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(ctx.Request)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				slog.Warn("认证失败", slog.String("path", ctx.Request.URL.Path),
+					slog.String("client", ctx.ClientIP()), slog.Any("err", err))
+				break
+			}
+
+			ctx.Set(principalKey, principal)
+			ctx.Next()
+			return
+		}
+
+		challenges := make(map[string]bool, len(authenticators))
+		for _, authenticator := range authenticators {
+			if challenge := authenticator.Challenge(); !challenges[challenge] {
+				challenges[challenge] = true
+				ctx.Writer.Header().Add("WWW-Authenticate", challenge)
+			}
+		}
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, Result{Message: "unauthorized"})
+	}
+}
+
+// GetPrincipal 获取认证通过的调用方，没有启用认证时返回 false
+func GetPrincipal(ctx *gin.Context) (Principal, bool) {
+	value, ok := ctx.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// bearerToken 优先读取 Authorization 头，浏览器直接打开下载以及预览链接时无法设置请求头，可以使用 access_token 查询参数
+func bearerToken(r *http.Request) (string, bool) {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token), true
+	}
+
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token, true
+	}
+
+	return "", false
+}
+
+type tokenAuthenticator struct {
+	// tokens Token 的 SHA256 到名称的映射，比较摘要避免通过耗时推测 Token 长度
+	tokens map[[sha256.Size]byte]string
+}
+
+// NewTokenAuthenticator tokens 为名称到 Token 的映射，通过 Bearer 传递
+func NewTokenAuthenticator(tokens map[string]string) Authenticator {
+	a := &tokenAuthenticator{tokens: make(map[[sha256.Size]byte]string, len(tokens))}
+	for name, token := range tokens {
+		if token == "" {
+			slog.Warn("忽略空的 API Token", slog.String("name", name))
+			continue
+		}
+		a.tokens[sha256.Sum256([]byte(token))] = name
+	}
+
+	return a
+}
+
+// Authenticate 不是配置的 Token 时返回 ErrNoCredentials，交给 JWT 继续认证
+func (a *tokenAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	for digest, name := range a.tokens {
+		if subtle.ConstantTimeCompare(digest[:], sum[:]) == 1 {
+			return Principal{Name: name, Method: "token"}, nil
+		}
+	}
+
+	return Principal{}, ErrNoCredentials
+}
+
+func (a *tokenAuthenticator) Challenge() string {
+	return "Bearer"
+}
@@ -0,0 +1,202 @@
+package ginx_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func TestAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bcrypt-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// apr1 以及 SHA1 摘要由 openssl passwd -apr1 以及 htpasswd -s 生成
+	htpasswd := strings.Join([]string{
+		"# comment",
+		"alice:" + string(bcryptHash),
+		"bob:$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/",
+		"carol:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+	}, "\n")
+	file := filepath.Join(dir, "htpasswd")
+	if err = os.WriteFile(file, []byte(htpasswd), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	middleware, err := ginx.NewAuthMiddleware(ginx.AuthConfig{
+		JWT:      &ginx.JWTConfig{Algorithm: "HS256", Secret: secret, Issuer: "ops"},
+		Tokens:   map[string]string{"ci": "static-token"},
+		Htpasswd: file,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := newEngine(middleware)
+
+	valid := jwt.MapClaims{"sub": "dave", "iss": "ops", "groups": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()}
+	tests := []struct {
+		name string
+		req  func(r *http.Request)
+		want string
+	}{
+		{name: "none", req: func(r *http.Request) {}},
+		{name: "token", req: bearer("static-token"), want: "ci/token/"},
+		{name: "unknown token", req: bearer("other-token")},
+		{name: "jwt", req: bearer(signHS(t, valid)), want: "dave/jwt/admin"},
+		{name: "jwt query", req: func(r *http.Request) {
+			r.URL.RawQuery = "access_token=" + signHS(t, valid)
+		}, want: "dave/jwt/admin"},
+		{name: "jwt expired", req: bearer(signHS(t, jwt.MapClaims{"sub": "dave", "iss": "ops", "exp": time.Now().Add(-time.Hour).Unix()}))},
+		{name: "jwt without exp", req: bearer(signHS(t, jwt.MapClaims{"sub": "dave", "iss": "ops"}))},
+		{name: "jwt issuer", req: bearer(signHS(t, jwt.MapClaims{"sub": "dave", "iss": "dev", "exp": time.Now().Add(time.Hour).Unix()}))},
+		{name: "jwt none", req: bearer(signNone(t, valid))},
+		{name: "basic bcrypt", req: basic("alice", "bcrypt-pass"), want: "alice/basic/"},
+		{name: "basic apr1", req: basic("bob", "secret"), want: "bob/basic/"},
+		{name: "basic sha1", req: basic("carol", "secret"), want: "carol/basic/"},
+		{name: "basic wrong password", req: basic("alice", "secret")},
+		{name: "basic unknown user", req: basic("eve", "secret")},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			tc.req(req)
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+
+			if tc.want == "" {
+				if rec.Code != http.StatusUnauthorized {
+					t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+				}
+				if got := rec.Header().Values("WWW-Authenticate"); len(got) != 2 {
+					t.Errorf("WWW-Authenticate = %v, want Bearer and Basic", got)
+				}
+				return
+			}
+
+			if rec.Code != http.StatusOK || rec.Body.String() != tc.want {
+				t.Errorf("response = %d %s, want %s", rec.Code, rec.Body.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "jwt.pem")
+	if err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	middleware, err := ginx.NewAuthMiddleware(ginx.AuthConfig{JWT: &ginx.JWTConfig{Algorithm: "RS256", PublicKey: file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := newEngine(middleware)
+
+	claims := jwt.MapClaims{"sub": "dave", "exp": time.Now().Add(time.Hour).Unix()}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, want := range map[string]int{
+		signed: http.StatusOK,
+		// 使用公钥作为 HS256 的密钥签名，只接受配置的算法时会被拒绝
+		signHSWithKey(t, claims, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})): http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		bearer(token)(req)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("status = %d, want %d", rec.Code, want)
+		}
+	}
+}
+
+func TestNewAuthMiddlewareInvalid(t *testing.T) {
+	for name, cfg := range map[string]ginx.AuthConfig{
+		"empty":         {},
+		"short secret":  {JWT: &ginx.JWTConfig{Algorithm: "HS256", Secret: "short"}},
+		"unknown alg":   {JWT: &ginx.JWTConfig{Algorithm: "none", Secret: secret}},
+		"missing key":   {JWT: &ginx.JWTConfig{Algorithm: "RS256", PublicKey: "missing.pem"}},
+		"missing users": {Htpasswd: "missing"},
+	} {
+		if _, err := ginx.NewAuthMiddleware(cfg); err == nil {
+			t.Errorf("NewAuthMiddleware %s should fail", name)
+		}
+	}
+}
+
+func newEngine(middleware gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(middleware)
+	engine.GET("/whoami", func(ctx *gin.Context) {
+		principal, _ := ginx.GetPrincipal(ctx)
+		ctx.String(http.StatusOK, "%s/%s/%s", principal.Name, principal.Method, strings.Join(principal.Groups, ","))
+	})
+
+	return engine
+}
+
+func bearer(token string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func basic(user, password string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.SetBasicAuth(user, password)
+	}
+}
+
+func signHS(t *testing.T, claims jwt.MapClaims) string {
+	return signHSWithKey(t, claims, []byte(secret))
+}
+
+func signHSWithKey(t *testing.T, claims jwt.MapClaims, key []byte) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func signNone(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
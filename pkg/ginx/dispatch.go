@@ -0,0 +1,22 @@
+package ginx
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Dispatch 根据查询参数 key 的值分发到对应的处理函数，兼容 VueFinder 单一入口的请求方式
+func Dispatch(key string, routes map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		action := ctx.Query(key)
+		fn, ok := routes[action]
+		if !ok {
+			ctx.PureJSON(http.StatusBadRequest, Result{
+				Message: "unsupported action: " + action,
+			})
+			return
+		}
+
+		fn(ctx)
+	}
+}
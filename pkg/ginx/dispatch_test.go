@@ -0,0 +1,50 @@
+package ginx_test
+
+import (
+	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	handler := func(name string) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, name)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/api", ginx.Dispatch("q", map[string]gin.HandlerFunc{"index": handler("index"), "preview": handler("preview")}))
+	engine.POST("/api", ginx.Dispatch("q", map[string]gin.HandlerFunc{"upload": handler("upload")}))
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		code   int
+		body   string
+	}{
+		{name: "index", method: http.MethodGet, target: "/api?q=index&path=/data", code: http.StatusOK, body: "index"},
+		{name: "preview", method: http.MethodGet, target: "/api?path=/data&q=preview", code: http.StatusOK, body: "preview"},
+		{name: "upload", method: http.MethodPost, target: "/api?q=upload", code: http.StatusOK, body: "upload"},
+		{name: "unknown", method: http.MethodGet, target: "/api?q=unknown", code: http.StatusBadRequest},
+		{name: "missing", method: http.MethodGet, target: "/api", code: http.StatusBadRequest},
+		// 只注册在 POST 上的操作不能通过 GET 调用，反之亦然
+		{name: "post only", method: http.MethodGet, target: "/api?q=upload", code: http.StatusBadRequest},
+		{name: "get only", method: http.MethodPost, target: "/api?q=index", code: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("status = %d %s, want %d", rec.Code, rec.Body.String(), tc.code)
+			}
+			if tc.body != "" && rec.Body.String() != tc.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tc.body)
+			}
+		})
+	}
+}
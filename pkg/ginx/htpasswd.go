@@ -0,0 +1,195 @@
+package ginx
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type basicAuthenticator struct {
+	file  string
+	realm string
+
+	mu sync.Mutex
+	// users 用户名到密码摘要的映射，文件修改后重新加载，增加用户不需要重启服务
+	users   map[string]string
+	modTime time.Time
+}
+
+// NewBasicAuthenticator 使用 htpasswd 文件校验 HTTP Basic 认证，支持 bcrypt、apr1 以及 SHA1 摘要
+func NewBasicAuthenticator(file, realm string) (Authenticator, error) {
+	a := &basicAuthenticator{file: file, realm: realm}
+	if _, err := a.load(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *basicAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+
+	users, err := a.load()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	hash, ok := users[user]
+	if !ok || !checkPassword(hash, password) {
+		return Principal{}, fmt.Errorf("%w: user %q", ErrInvalidCredentials, user)
+	}
+
+	return Principal{Name: user, Method: "basic"}, nil
+}
+
+func (a *basicAuthenticator) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q", a.realm)
+}
+
+// load 文件没有变化时直接返回缓存，重新加载失败时继续使用上一次的结果
+func (a *basicAuthenticator) load() (map[string]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.file)
+	if err != nil {
+		if a.users != nil {
+			return a.users, nil
+		}
+		return nil, err
+	}
+
+	if a.users != nil && info.ModTime().Equal(a.modTime) {
+		return a.users, nil
+	}
+
+	users, err := readHtpasswd(a.file)
+	if err != nil {
+		if a.users != nil {
+			return a.users, nil
+		}
+		return nil, err
+	}
+
+	a.users, a.modTime = users, info.ModTime()
+	return users, nil
+}
+
+func readHtpasswd(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("parse htpasswd %s: invalid line %q", file, user)
+		}
+		users[user] = hash
+	}
+
+	return users, scanner.Err()
+}
+
+// checkPassword 不支持 crypt 以及明文密码
+func checkPassword(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		return subtle.ConstantTimeCompare([]byte(apr1(password, salt)), []byte(hash)) == 1
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(sum[:])), []byte(hash[5:])) == 1
+	default:
+		return false
+	}
+}
+
+// apr1 Apache 基于 MD5 的摘要，htpasswd -m 的默认格式
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	pw := []byte(password)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write([]byte(salt))
+	alt.Write(pw)
+	sum := alt.Sum(nil)
+
+	d := md5.New()
+	d.Write(pw)
+	d.Write([]byte(magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		d.Write(sum[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			d.Write([]byte{0})
+		} else {
+			d.Write(pw[:1])
+		}
+	}
+	sum = d.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		r := md5.New()
+		if i&1 != 0 {
+			r.Write(pw)
+		} else {
+			r.Write(sum)
+		}
+		if i%3 != 0 {
+			r.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			r.Write(pw)
+		}
+		if i&1 != 0 {
+			r.Write(sum)
+		} else {
+			r.Write(pw)
+		}
+		sum = r.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	out := make([]byte, 0, 22)
+	encode := func(a, b, c byte, n int) {
+		v := uint(a)<<16 | uint(b)<<8 | uint(c)
+		for ; n > 0; n-- {
+			out = append(out, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	encode(sum[0], sum[6], sum[12], 4)
+	encode(sum[1], sum[7], sum[13], 4)
+	encode(sum[2], sum[8], sum[14], 4)
+	encode(sum[3], sum[9], sum[15], 4)
+	encode(sum[4], sum[10], sum[5], 4)
+	encode(0, 0, sum[11], 2)
+
+	return magic + salt + "$" + string(out)
+}
@@ -0,0 +1,105 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// jwtLeeway 校验过期时间时允许的时钟误差
+const jwtLeeway = time.Minute
+
+// JWTConfig JWT 签名算法以及密钥，只接受配置的算法，避免算法混淆攻击
+type JWTConfig struct {
+	// Algorithm 可选 HS256、HS384、HS512、RS256、RS384、RS512
+	Algorithm string `json:"algorithm"`
+	// Secret HS 系列算法的密钥
+	Secret string `json:"secret"`
+	// PublicKey RS 系列算法的 PEM 格式公钥文件
+	PublicKey string `json:"public_key"`
+	// Issuer Audience 不为空时校验 iss 以及 aud 声明
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+}
+
+type jwtAuthenticator struct {
+	key    any
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator Token 必须包含 exp 以及 sub 声明，sub 作为 Principal 的 Name
+func NewJWTAuthenticator(cfg JWTConfig) (Authenticator, error) {
+	var key any
+	switch {
+	case strings.HasPrefix(cfg.Algorithm, "HS"):
+		if len(cfg.Secret) < 32 {
+			return nil, errors.New("jwt secret must be at least 32 bytes")
+		}
+		key = []byte(cfg.Secret)
+	case strings.HasPrefix(cfg.Algorithm, "RS"):
+		data, err := os.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return nil, fmt.Errorf("parse jwt public key %s: %w", cfg.PublicKey, err)
+		}
+	}
+
+	if method := jwt.GetSigningMethod(cfg.Algorithm); key == nil || method == nil {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", cfg.Algorithm)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &jwtAuthenticator{key: key, parser: jwt.NewParser(options...)}, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	raw, ok := bearerToken(r)
+	if !ok {
+		return Principal{}, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(raw, claims, func(*jwt.Token) (any, error) {
+		return a.key, nil
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: missing sub claim", ErrInvalidCredentials)
+	}
+
+	principal := Principal{Name: subject, Method: "jwt"}
+	if groups, ok := claims["groups"].([]any); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				principal.Groups = append(principal.Groups, name)
+			}
+		}
+	}
+
+	return principal, nil
+}
+
+func (a *jwtAuthenticator) Challenge() string {
+	return "Bearer"
+}
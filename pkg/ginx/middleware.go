@@ -1,8 +1,11 @@
 package ginx
 
 import (
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"io"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -12,7 +15,6 @@ func corsHdl() gin.HandlerFunc {
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"POST", "GET"},
 		AllowHeaders:     []string{"Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"x-jwt-token", "x-refresh-token"},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			if strings.HasPrefix(origin, "http://localhost") {
@@ -25,8 +27,57 @@ func corsHdl() gin.HandlerFunc {
 	})
 }
 
+// Logger 与 gin 默认格式相同的访问日志，查询参数中的 access_token 替换为 REDACTED，避免 Token 写入日志
+func Logger(out io.Writer) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Output: out,
+		Formatter: func(param gin.LogFormatterParams) string {
+			param.Path = redactQuery(param.Path)
+
+			var statusColor, methodColor, resetColor string
+			if param.IsOutputColor() {
+				statusColor, methodColor, resetColor = param.StatusCodeColor(), param.MethodColor(), param.ResetColor()
+			}
+			if param.Latency > time.Minute {
+				param.Latency = param.Latency.Truncate(time.Second)
+			}
+
+			return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				statusColor, param.StatusCode, resetColor,
+				param.Latency,
+				param.ClientIP,
+				methodColor, param.Method, resetColor,
+				param.Path,
+				param.ErrorMessage,
+			)
+		},
+	})
+}
+
+// redactQuery 参数名可能经过编码，解析后再判断，查询参数无法解析时整体隐藏
+func redactQuery(p string) string {
+	base, raw, ok := strings.Cut(p, "?")
+	if !ok {
+		return p
+	}
+
+	query, err := url.ParseQuery(raw)
+	switch {
+	case err != nil:
+		return base + "?REDACTED"
+	case !query.Has("access_token"):
+		return p
+	}
+
+	query.Set("access_token", "REDACTED")
+	return base + "?" + query.Encode()
+}
+
 func NewMiddleware() []gin.HandlerFunc {
 	return []gin.HandlerFunc{
+		Logger(gin.DefaultWriter),
+		gin.Recovery(),
 		corsHdl(),
 	}
 }
@@ -0,0 +1,34 @@
+package ginx_test
+
+import (
+	"bytes"
+	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRedactsAccessToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for target, want := range map[string]string{
+		"/api/finder/download?id=20&access_token=secret-token":  "/api/finder/download?access_token=REDACTED&id=20",
+		"/api/finder/download?access%5Ftoken=secret-token":      "/api/finder/download?access_token=REDACTED",
+		"/api/finder/download?id=20&access_token=secret-token;": "/api/finder/download?REDACTED",
+		"/api/finder/download?id=20":                            "/api/finder/download?id=20",
+	} {
+		var buf bytes.Buffer
+		engine := gin.New()
+		engine.Use(ginx.Logger(&buf))
+		engine.GET("/api/finder/download", func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+
+		if log := buf.String(); strings.Contains(log, "secret-token") || !strings.Contains(log, `"`+want+`"`) {
+			t.Errorf("log of %s = %q, want path %s", target, log, want)
+		}
+	}
+}
@@ -1,7 +1,23 @@
 package ginx
 
+import (
+	"io"
+	"time"
+)
+
 type Result struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data"`
 }
+
+// Stream 流式返回的数据，写入完成后会自动关闭
+// 支持 Range 以及 If-Range、If-None-Match、If-Modified-Since 等条件请求
+type Stream struct {
+	io.ReadSeekCloser
+	Name    string
+	ModTime time.Time
+	ETag    string
+	// ContentType 为空时根据文件名称以及内容自动识别
+	ContentType string
+}
@@ -1,17 +1,28 @@
 package ginx
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"io/fs"
 	"log/slog"
 	"net/http"
 )
 
+// errorStatus 没有权限返回 403，包括授权策略拒绝以及远程文件系统的权限错误
+func errorStatus(err error) int {
+	if errors.Is(err, fs.ErrPermission) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusInternalServerError
+}
+
 func Wrap(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		res, err := fn(ctx)
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.PureJSON(http.StatusInternalServerError, res)
+			ctx.PureJSON(errorStatus(err), res)
 			return
 		}
 		ctx.PureJSON(http.StatusOK, res.Data)
@@ -29,46 +40,42 @@ func WrapBuffBody[Req any](fn func(ctx *gin.Context, req Req) (Result, error)) g
 		res, err := fn(ctx, req)
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.PureJSON(http.StatusInternalServerError, res)
+			ctx.PureJSON(errorStatus(err), res)
 			return
 		}
 		ctx.String(http.StatusOK, "%s", res.Data)
 	}
 }
 
-func WrapBuff(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
+func WrapStream(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		res, err := fn(ctx)
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.PureJSON(http.StatusInternalServerError, res)
+			ctx.PureJSON(errorStatus(err), res)
 			return
 		}
-		ctx.String(http.StatusOK, "%s", res.Data)
-	}
-}
 
-func WrapData(fn func(ctx *gin.Context) (Result, error)) gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		res, err := fn(ctx)
-		if err != nil {
-			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.PureJSON(http.StatusInternalServerError, res)
-			return
-		}
-
-		// 将 res.Data 转换为 []byte 类型
-		data, ok := res.Data.([]byte)
+		// 将 res.Data 转换为 Stream 类型
+		stream, ok := res.Data.(Stream)
 		if !ok {
-			slog.Error("res.Data 不是 []byte 类型")
+			slog.Error("res.Data 不是 Stream 类型")
 			ctx.PureJSON(http.StatusInternalServerError, gin.H{
 				"error": "无法处理返回的数据",
 			})
 			return
 		}
+		defer stream.Close()
+
+		if stream.ContentType != "" {
+			ctx.Header("Content-Type", stream.ContentType)
+		}
+		if stream.ETag != "" {
+			ctx.Header("ETag", stream.ETag)
+		}
 
-		// 发送二进制数据
-		ctx.Data(http.StatusOK, "application/octet-stream", data)
+		// 按需读取请求的范围，避免整个文件加载到内存
+		http.ServeContent(ctx.Writer, ctx.Request, stream.Name, stream.ModTime, stream)
 	}
 }
 
@@ -83,7 +90,7 @@ func WrapBody[Req any](fn func(ctx *gin.Context, req Req) (Result, error)) gin.H
 		res, err := fn(ctx, req)
 		if err != nil {
 			slog.Error("执行业务逻辑失败", slog.Any("err", err))
-			ctx.PureJSON(http.StatusInternalServerError, res)
+			ctx.PureJSON(errorStatus(err), res)
 			return
 		}
 		ctx.PureJSON(http.StatusOK, res.Data)
@@ -0,0 +1,260 @@
+// Package policy 基于路径的授权策略，按照调用方、会话以及路径前缀判断允许的操作
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Action 文件操作的类别
+type Action string
+
+const (
+	// Read 列出目录、搜索、下载以及预览
+	Read Action = "read"
+	// Write 创建、上传、保存、重命名、修改权限，以及复制、移动、解压的目标位置
+	Write Action = "write"
+	// Delete 删除，以及移动的源文件
+	Delete Action = "delete"
+	// Manage 通过会话接口创建以及关闭会话，只有管理员允许，不能在规则中使用
+	Manage Action = "manage"
+)
+
+// Subject 调用方，没有启用认证时为匿名调用方，只能匹配 * 或者没有限制调用方的规则
+type Subject struct {
+	Name   string
+	Groups []string
+}
+
+// Rule 一条授权规则，Users Groups Finders 为空时不限制
+type Rule struct {
+	// Users 用户名，* 匹配所有调用方
+	Users []string `json:"users"`
+	// Groups 用户组，调用方属于其中任意一个即可
+	Groups []string `json:"groups"`
+	// Finders 会话 id
+	Finders []int64 `json:"finders"`
+	// Path 绝对路径，匹配该路径以及其下的所有文件
+	Path string `json:"path"`
+	// Allow 允许的操作，为空表示禁止所有操作，用于隐藏子目录
+	Allow []Action `json:"allow"`
+}
+
+// Admins 管理员，可以通过会话接口创建以及关闭会话，为空时没有管理员
+type Admins struct {
+	// Users 用户名，* 匹配所有调用方
+	Users []string `json:"users"`
+	// Groups 用户组，调用方属于其中任意一个即可
+	Groups []string `json:"groups"`
+}
+
+// Policy 同一个路径匹配多条规则时，路径最长的规则生效，路径长度相同的规则允许的操作合并
+// 没有任何规则匹配时拒绝，例如 ops 组可以修改 /srv/app，其他人只读：
+//
+//	{"groups": ["ops"], "path": "/srv/app", "allow": ["read", "write", "delete"]}
+//	{"users": ["*"], "path": "/", "allow": ["read"]}
+type Policy struct {
+	rules  []Rule
+	admins Admins
+}
+
+// Error 操作被策略拒绝，可以通过 errors.Is(err, fs.ErrPermission) 判断
+type Error struct {
+	Subject string
+	Action  Action
+	Path    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s is not allowed to %s %s", e.Subject, e.Action, e.Path)
+}
+
+func (e *Error) Unwrap() error {
+	return fs.ErrPermission
+}
+
+// Load 从 JSON 文件加载策略，格式为 {"admins": {...}, "rules": [...]}
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg struct {
+		Admins Admins `json:"admins"`
+		Rules  []Rule `json:"rules"`
+	}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", file, err)
+	}
+
+	return NewWithAdmins(cfg.Admins, cfg.Rules)
+}
+
+// New 校验规则中的路径以及操作，没有管理员
+func New(rules []Rule) (*Policy, error) {
+	return NewWithAdmins(Admins{}, rules)
+}
+
+func NewWithAdmins(admins Admins, rules []Rule) (*Policy, error) {
+	for i, rule := range rules {
+		if !path.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("rule %d: path %q must be absolute", i, rule.Path)
+		}
+		rules[i].Path = path.Clean(rule.Path)
+
+		for _, action := range rule.Allow {
+			if action != Read && action != Write && action != Delete {
+				return nil, fmt.Errorf("rule %d: unknown action %q", i, action)
+			}
+		}
+	}
+
+	return &Policy{rules: rules, admins: admins}, nil
+}
+
+// CheckAdmin 不是管理员时返回 *Error
+func (p *Policy) CheckAdmin(sub Subject) error {
+	if !p.Admin(sub) {
+		return &Error{Subject: subjectName(sub), Action: Manage, Path: "sessions"}
+	}
+
+	return nil
+}
+
+// Admin 匿名调用方只能匹配 *
+func (p *Policy) Admin(sub Subject) bool {
+	if slices.Contains(p.admins.Users, "*") || sub.Name != "" && slices.Contains(p.admins.Users, sub.Name) {
+		return true
+	}
+
+	for _, group := range sub.Groups {
+		if group != "" && slices.Contains(p.admins.Groups, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check 只检查 file 本身，不允许时返回 *Error
+func (p *Policy) Check(sub Subject, finder int64, action Action, file string) error {
+	if !p.Allowed(sub, finder, action, file) {
+		return &Error{Subject: subjectName(sub), Action: action, Path: file}
+	}
+
+	return nil
+}
+
+// CheckTree 目录的删除、移动、复制、打包以及递归修改权限会作用于其下所有文件，file 之下单独配置了规则的路径也需要允许
+// 不允许时返回 *Error，Path 为被拒绝的路径
+func (p *Policy) CheckTree(sub Subject, finder int64, action Action, file string) error {
+	if err := p.Check(sub, finder, action, file); err != nil {
+		return err
+	}
+
+	if denied, ok := p.deniedUnder(sub, finder, action, file); ok {
+		return &Error{Subject: subjectName(sub), Action: action, Path: denied}
+	}
+
+	return nil
+}
+
+// AllowedTree file 以及其下所有单独配置了规则的路径都允许 action
+func (p *Policy) AllowedTree(sub Subject, finder int64, action Action, file string) bool {
+	return p.CheckTree(sub, finder, action, file) == nil
+}
+
+// deniedUnder 返回 file 之下第一个不允许 action 的规则路径
+func (p *Policy) deniedUnder(sub Subject, finder int64, action Action, file string) (string, bool) {
+	file = path.Clean(file)
+	for _, rule := range p.rules {
+		if rule.matches(sub, finder) && rule.Path != file && within(file, rule.Path) &&
+			!p.Allowed(sub, finder, action, rule.Path) {
+			return rule.Path, true
+		}
+	}
+
+	return "", false
+}
+
+// Allowed 相对路径不会匹配任何规则，总是拒绝
+func (p *Policy) Allowed(sub Subject, finder int64, action Action, file string) bool {
+	file = path.Clean(file)
+	longest, allowed := -1, false
+	for _, rule := range p.rules {
+		if !rule.matches(sub, finder) || !within(rule.Path, file) {
+			continue
+		}
+
+		switch {
+		case len(rule.Path) > longest:
+			longest, allowed = len(rule.Path), slices.Contains(rule.Allow, action)
+		case len(rule.Path) == longest:
+			allowed = allowed || slices.Contains(rule.Allow, action)
+		}
+	}
+
+	return allowed
+}
+
+// Visible 可以读取，或者是可读路径的上级目录，上级目录需要展示才能逐层进入可读的目录
+func (p *Policy) Visible(sub Subject, finder int64, file string) bool {
+	if p.Allowed(sub, finder, Read, file) {
+		return true
+	}
+
+	file = path.Clean(file)
+	for _, rule := range p.rules {
+		if rule.matches(sub, finder) && rule.Path != file && within(file, rule.Path) &&
+			p.Allowed(sub, finder, Read, rule.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) matches(sub Subject, finder int64) bool {
+	if len(r.Finders) > 0 && !slices.Contains(r.Finders, finder) {
+		return false
+	}
+
+	if len(r.Users) == 0 && len(r.Groups) == 0 {
+		return true
+	}
+
+	if slices.Contains(r.Users, "*") || sub.Name != "" && slices.Contains(r.Users, sub.Name) {
+		return true
+	}
+
+	for _, group := range sub.Groups {
+		if group != "" && slices.Contains(r.Groups, group) {
+			return true
+		}
+	}
+
+	return slices.Contains(r.Groups, "*")
+}
+
+// within path 等于 dir 或者位于 dir 之下
+func within(dir, file string) bool {
+	if !path.IsAbs(file) {
+		return false
+	}
+
+	return dir == "/" || file == dir || strings.HasPrefix(file, dir+"/")
+}
+
+func subjectName(sub Subject) string {
+	if sub.Name == "" {
+		return "anonymous"
+	}
+
+	return sub.Name
+}
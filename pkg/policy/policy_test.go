@@ -0,0 +1,153 @@
+package policy_test
+
+import (
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/policy"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	err := os.WriteFile(file, []byte(`{"rules": [
+		{"groups": ["ops"], "path": "/srv/app", "allow": ["read", "write", "delete"]},
+		{"users": ["*"], "path": "/", "allow": ["read"]},
+		{"users": ["*"], "path": "/srv/app/secret", "allow": []},
+		{"groups": ["ops"], "path": "/srv/app/secret", "allow": ["read"]},
+		{"users": ["alice"], "finders": [20], "path": "/home/alice", "allow": ["read", "write"]}
+	]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := policy.Subject{Name: "bob", Groups: []string{"ops"}}
+	alice := policy.Subject{Name: "alice"}
+	tests := []struct {
+		name   string
+		sub    policy.Subject
+		finder int64
+		action policy.Action
+		path   string
+		want   bool
+	}{
+		{name: "ops write", sub: ops, finder: 20, action: policy.Write, path: "/srv/app/config.yaml", want: true},
+		{name: "ops write dir", sub: ops, finder: 20, action: policy.Write, path: "/srv/app", want: true},
+		{name: "ops write sibling", sub: ops, finder: 20, action: policy.Write, path: "/srv/application", want: false},
+		{name: "ops write dot dot", sub: ops, finder: 20, action: policy.Write, path: "/srv/app/../etc", want: false},
+		{name: "ops read", sub: ops, finder: 20, action: policy.Read, path: "/etc/passwd", want: true},
+		{name: "ops read secret", sub: ops, finder: 20, action: policy.Read, path: "/srv/app/secret/key", want: true},
+		{name: "ops write secret", sub: ops, finder: 20, action: policy.Write, path: "/srv/app/secret/key", want: false},
+		{name: "others read", sub: alice, finder: 30, action: policy.Read, path: "/srv/app/config.yaml", want: true},
+		{name: "others write", sub: alice, finder: 30, action: policy.Write, path: "/srv/app/config.yaml", want: false},
+		{name: "others read secret", sub: alice, finder: 30, action: policy.Read, path: "/srv/app/secret/key", want: false},
+		{name: "finder rule", sub: alice, finder: 20, action: policy.Write, path: "/home/alice/notes", want: true},
+		{name: "other finder", sub: alice, finder: 30, action: policy.Write, path: "/home/alice/notes", want: false},
+		{name: "anonymous read", finder: 20, action: policy.Read, path: "/etc", want: true},
+		{name: "anonymous delete", finder: 20, action: policy.Delete, path: "/tmp/file", want: false},
+		{name: "relative", sub: ops, finder: 20, action: policy.Read, path: "srv/app", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := p.Allowed(tc.sub, tc.finder, tc.action, tc.path); got != tc.want {
+				t.Errorf("Allowed = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// 删除目录会删除其下只读的 secret 目录，只检查目录本身时允许
+	if err = p.Check(ops, 20, policy.Delete, "/srv/app"); err != nil {
+		t.Errorf("Check delete /srv/app = %v", err)
+	}
+	var denied *policy.Error
+	err = p.CheckTree(ops, 20, policy.Delete, "/srv/app")
+	if !errors.Is(err, fs.ErrPermission) || !errors.As(err, &denied) || denied.Path != "/srv/app/secret" {
+		t.Errorf("CheckTree delete /srv/app = %v, want /srv/app/secret denied", err)
+	}
+	if err = p.CheckTree(ops, 20, policy.Delete, "/srv/app/data"); err != nil {
+		t.Errorf("CheckTree delete /srv/app/data = %v", err)
+	}
+	if p.AllowedTree(ops, 20, policy.Write, "/srv") || !p.AllowedTree(ops, 20, policy.Read, "/srv") {
+		t.Error("ops should read but not write the whole /srv tree")
+	}
+	// ops 组可以读取 secret，其他调用方不能读取整个 /srv
+	if p.AllowedTree(alice, 30, policy.Read, "/srv") {
+		t.Error("alice should not read the whole /srv tree")
+	}
+
+	if !p.Visible(alice, 30, "/srv/app") || p.Visible(alice, 30, "/srv/app/secret") {
+		t.Error("alice should see /srv/app but not /srv/app/secret")
+	}
+
+	// 没有读权限的 /home 需要展示，才能进入 /home/alice
+	home, err := policy.New([]policy.Rule{{Users: []string{"alice"}, Path: "/home/alice", Allow: []policy.Action{policy.Read}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home.Allowed(alice, 20, policy.Read, "/home") || !home.Visible(alice, 20, "/home") || home.Visible(alice, 20, "/srv") {
+		t.Error("alice should only see /home on the way to /home/alice")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for name, rules := range map[string][]policy.Rule{
+		"relative path":  {{Path: "srv", Allow: []policy.Action{policy.Read}}},
+		"unknown action": {{Path: "/srv", Allow: []policy.Action{"execute"}}},
+		"manage action":  {{Path: "/srv", Allow: []policy.Action{policy.Manage}}},
+	} {
+		if _, err := policy.New(rules); err == nil {
+			t.Errorf("New %s should fail", name)
+		}
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	p, err := policy.NewWithAdmins(policy.Admins{Users: []string{"alice"}, Groups: []string{"ops"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		sub  policy.Subject
+		want bool
+	}{
+		{name: "user", sub: policy.Subject{Name: "alice"}, want: true},
+		{name: "group", sub: policy.Subject{Name: "bob", Groups: []string{"dev", "ops"}}, want: true},
+		{name: "other", sub: policy.Subject{Name: "bob", Groups: []string{"dev"}}},
+		{name: "anonymous", sub: policy.Subject{}},
+	} {
+		if got := p.Admin(tc.sub); got != tc.want {
+			t.Errorf("Admin %s = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	err = p.CheckAdmin(policy.Subject{Name: "bob"})
+	var denied *policy.Error
+	if !errors.Is(err, fs.ErrPermission) || !errors.As(err, &denied) || denied.Action != policy.Manage {
+		t.Errorf("CheckAdmin bob = %v, want manage denied", err)
+	}
+
+	// 没有配置管理员时，包括匿名调用方在内都不是管理员
+	none, err := policy.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if none.Admin(policy.Subject{Name: "alice"}) {
+		t.Error("policy without admins should not have admins")
+	}
+	everyone, err := policy.NewWithAdmins(policy.Admins{Users: []string{"*"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !everyone.Admin(policy.Subject{}) {
+		t.Error("* should match anonymous callers")
+	}
+}
@@ -0,0 +1,311 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrHostNotAllowed 凭证不允许连接该主机
+	ErrHostNotAllowed = fmt.Errorf("host is not allowed for the credential: %w", fs.ErrPermission)
+)
+
+const (
+	KindSftp   = "sftp"
+	KindLocal  = "local"
+	KindMemory = "memory"
+	KindS3     = "s3"
+)
+
+// Session 文件浏览会话，id 即前端请求中携带的 finder id
+type Session struct {
+	Id         int64  `json:"id"`
+	Kind       string `json:"kind"`
+	Host       string `json:"host,omitempty"`
+	User       string `json:"user,omitempty"`
+	Credential string `json:"credential,omitempty"`
+	// Root 会话在主机上被限制的目录，为空时不限制
+	Root string `json:"root,omitempty"`
+	// ReadOnly 只允许浏览、搜索、下载以及预览
+	ReadOnly  bool  `json:"read_only,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+	// Status SSH 连接状态，其他类型的会话为空
+	Status *sshx.Status `json:"status,omitempty"`
+
+	Finder finder.Finder `json:"-"`
+	conn   *sshx.Conn
+}
+
+// Credential 服务端预先配置的 SSH 凭证
+type Credential struct {
+	sshx.Auth
+	// Hosts 允许使用该凭证连接的主机，例如 10.0.0.8:22，支持 path.Match 通配符，为空时不允许连接任何主机
+	Hosts []string `json:"hosts"`
+	// Root 使用该凭证的会话限制在该目录下，为空时不限制
+	Root string `json:"root"`
+	// ReadOnly 使用该凭证的会话只允许浏览、搜索、下载以及预览，客户端无法解除
+	ReadOnly bool `json:"read_only"`
+}
+
+// allows host 匹配 Hosts 中任意一项
+func (c Credential) allows(host string) bool {
+	return slices.ContainsFunc(c.Hosts, func(pattern string) bool {
+		ok, _ := path.Match(pattern, host)
+		return ok
+	})
+}
+
+// jail 将 f 限制在凭证的根目录下，root 只能在其中进一步限制，返回主机上实际的根目录
+// root 单独嵌套一层，root 中的软链接也无法超出凭证的根目录
+func (c Credential) jail(f finder.Finder, root string) (finder.Finder, string) {
+	if c.Root != "" {
+		f = finder.NewJailFinder(f, c.Root)
+	}
+	if root != "" {
+		f = finder.NewJailFinder(f, root)
+	}
+
+	if c.Root == "" && root == "" {
+		return f, ""
+	}
+	return f, path.Join("/", c.Root, path.Clean("/"+root))
+}
+
+// readOnly 客户端只能额外要求只读，不能解除凭证配置的只读
+func (c Credential) readOnly(req OpenReq) bool {
+	return c.ReadOnly || req.ReadOnly
+}
+
+// OpenReq 打开 SSH 会话，凭证只能引用服务端预先配置的名称，避免密码经过接口传输
+type OpenReq struct {
+	Host       string
+	User       string
+	Credential string
+	// Root 在凭证的根目录之内进一步限制，路径相对于凭证的根目录，客户端看到的路径以该目录为 /
+	Root string
+	// ReadOnly 修改文件的操作返回 finder.ErrReadOnly，凭证配置了只读时忽略
+	ReadOnly bool
+}
+
+// Manager 管理所有会话，可以并发访问
+type Manager struct {
+	mu       sync.RWMutex
+	sessions map[int64]*Session
+	nextId   int64
+
+	credentials map[string]Credential
+	// base 新建 SSH 连接时使用的公共配置，例如主机密钥校验策略以及心跳间隔
+	base sshx.Config
+}
+
+func NewManager(credentials map[string]Credential, base sshx.Config) *Manager {
+	if credentials == nil {
+		credentials = make(map[string]Credential)
+	}
+
+	return &Manager{
+		sessions:    make(map[int64]*Session),
+		nextId:      100,
+		credentials: credentials,
+		base:        base,
+	}
+}
+
+// LoadCredentials 从 JSON 文件加载凭证，格式为 名称 -> Credential
+func LoadCredentials(file string) (map[string]Credential, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials map[string]Credential
+	if err = json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("parse credentials %s: %w", file, err)
+	}
+
+	for name, credential := range credentials {
+		for _, pattern := range credential.Hosts {
+			if _, err = path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("credential %s: host %q: %w", name, pattern, err)
+			}
+		}
+	}
+
+	return credentials, nil
+}
+
+// Register 注册启动时创建的会话，使用固定的 id
+func (m *Manager) Register(id int64, kind string, f finder.Finder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[id] = &Session{
+		Id:        id,
+		Kind:      kind,
+		ReadOnly:  finder.CapabilitiesOf(f).ReadOnly,
+		CreatedAt: time.Now().Unix(),
+		Finder:    f,
+	}
+}
+
+// Connect 使用指定的凭证连接 SSH 服务器，不检查凭证允许的主机，id 为 0 时自动分配，req 中的 Credential 只用于展示
+func (m *Manager) Connect(id int64, req OpenReq, credential Credential) (Session, error) {
+	return m.connect(id, req, credential)
+}
+
+// Open 使用预先配置的凭证连接 SSH 服务器，只能连接凭证允许的主机，返回自动分配 id 的会话
+func (m *Manager) Open(req OpenReq) (Session, error) {
+	if req.Host == "" || req.User == "" {
+		return Session{}, errors.New("host and user are required")
+	}
+
+	m.mu.RLock()
+	credential, ok := m.credentials[req.Credential]
+	m.mu.RUnlock()
+	if !ok {
+		return Session{}, fmt.Errorf("%w: %s", ErrCredentialNotFound, req.Credential)
+	}
+	if !credential.allows(req.Host) {
+		return Session{}, fmt.Errorf("%w: %s cannot connect to %s", ErrHostNotAllowed, req.Credential, req.Host)
+	}
+
+	return m.connect(0, req, credential)
+}
+
+func (m *Manager) connect(id int64, req OpenReq, credential Credential) (Session, error) {
+	cfg := m.base
+	cfg.Host, cfg.User, cfg.Auth = req.Host, req.User, credential.Auth
+
+	// 建立连接比较耗时，不持有锁
+	conn, err := sshx.NewConn(cfg)
+	if err != nil {
+		return Session{}, err
+	}
+
+	f, root := credential.jail(finder.NewSftpFinderWithConn(conn), req.Root)
+	readOnly := credential.readOnly(req)
+	if readOnly {
+		f = finder.NewReadOnlyFinder(f)
+	}
+
+	s := &Session{
+		Kind:       KindSftp,
+		Host:       req.Host,
+		User:       req.User,
+		Credential: req.Credential,
+		Root:       root,
+		ReadOnly:   readOnly,
+		CreatedAt:  time.Now().Unix(),
+		Finder:     f,
+		conn:       conn,
+	}
+
+	m.mu.Lock()
+	if id == 0 {
+		id = m.allocId()
+	}
+	old := m.sessions[id]
+	s.Id = id
+	m.sessions[id] = s
+	m.mu.Unlock()
+
+	if old != nil {
+		_ = old.release()
+	}
+
+	return s.snapshot(), nil
+}
+
+// allocId 分配未被占用的 id，调用方需要持有写锁
+func (m *Manager) allocId() int64 {
+	for {
+		m.nextId++
+		if _, ok := m.sessions[m.nextId]; !ok {
+			return m.nextId
+		}
+	}
+}
+
+// Finder 获取会话对应的 Finder
+func (m *Manager) Finder(id int64) (finder.Finder, error) {
+	s, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Finder, nil
+}
+
+// Get 获取会话信息
+func (m *Manager) Get(id int64) (Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+
+	return s.snapshot(), nil
+}
+
+// List 按照 id 排序返回所有会话
+func (m *Manager) List() []Session {
+	m.mu.RLock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s.snapshot())
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Id < sessions[j].Id
+	})
+
+	return sessions
+}
+
+// Close 关闭会话并释放连接
+func (m *Manager) Close(id int64) error {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	return s.release()
+}
+
+// snapshot 复制会话信息，同时获取最新的连接状态
+func (s *Session) snapshot() Session {
+	c := *s
+	if s.conn != nil {
+		status := s.conn.Status()
+		c.Status = &status
+	}
+
+	return c
+}
+
+func (s *Session) release() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.Close()
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialJail(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{"srv/app/config.yaml": "app", "srv/other/config.yaml": "other", "etc/passwd": "root"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// escape 位于凭证的根目录之内，但是指向根目录以外
+	if err := os.Symlink(filepath.Join(dir, "etc"), filepath.Join(dir, "srv", "escape")); err != nil {
+		t.Fatal(err)
+	}
+	inner := finder.NewLocalFinder(dir)
+
+	for _, tc := range []struct {
+		name       string
+		credential Credential
+		root       string
+		wantRoot   string
+		file       string
+		// want 为空时期望 ErrOutsideRoot
+		want string
+	}{
+		{name: "unconfined", file: "/srv/app/config.yaml", want: "app"},
+		{name: "client root", root: "/srv/app", wantRoot: "/srv/app", file: "/config.yaml", want: "app"},
+		{name: "credential root", credential: Credential{Root: "/srv"}, wantRoot: "/srv", file: "/app/config.yaml", want: "app"},
+		{name: "narrowed", credential: Credential{Root: "/srv"}, root: "/app", wantRoot: "/srv/app", file: "/config.yaml", want: "app"},
+		// 客户端的根目录相对于凭证的根目录，.. 无法超出
+		{name: "narrowed with dots", credential: Credential{Root: "/srv"}, root: "../../other", wantRoot: "/srv/other",
+			file: "/config.yaml", want: "other"},
+		{name: "narrowed to symlink", credential: Credential{Root: "/srv"}, root: "/escape", wantRoot: "/srv/escape", file: "/passwd"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, root := tc.credential.jail(inner, tc.root)
+			if root != tc.wantRoot {
+				t.Errorf("root = %q, want %q", root, tc.wantRoot)
+			}
+
+			content, err := f.Download(context.Background(), tc.file)
+			if tc.want == "" {
+				if !errors.Is(err, finder.ErrOutsideRoot) {
+					t.Fatalf("Download %s = %v, want ErrOutsideRoot", tc.file, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Download %s: %v", tc.file, err)
+			}
+			defer content.Close()
+
+			data, err := io.ReadAll(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.want {
+				t.Errorf("Download %s = %q, want %q", tc.file, data, tc.want)
+			}
+		})
+	}
+}
+
+func TestCredentialReadOnly(t *testing.T) {
+	for _, tc := range []struct {
+		credential bool
+		req        bool
+		want       bool
+	}{
+		{},
+		{req: true, want: true},
+		{credential: true, want: true},
+		{credential: true, req: true, want: true},
+	} {
+		if got := (Credential{ReadOnly: tc.credential}).readOnly(OpenReq{ReadOnly: tc.req}); got != tc.want {
+			t.Errorf("readOnly credential %v request %v = %v, want %v", tc.credential, tc.req, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package sshx
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"net"
+	"os"
+)
+
+// Auth SSH 认证方式，可以同时配置多种，按照 公钥 -> 密码 -> 键盘交互 的顺序尝试
+type Auth struct {
+	// Password 密码认证，同时作为键盘交互认证的应答
+	Password string `json:"password"`
+	// KeyFile 私钥文件路径
+	KeyFile string `json:"key_file"`
+	// Passphrase 私钥密码
+	Passphrase string `json:"passphrase"`
+	// CertFile OpenSSH 证书路径，需要与 KeyFile 配合使用
+	CertFile string `json:"cert_file"`
+	// Agent 使用 SSH_AUTH_SOCK 指向的 ssh-agent
+	Agent bool `json:"agent"`
+	// KeyboardInteractive 键盘交互认证
+	KeyboardInteractive bool `json:"keyboard_interactive"`
+}
+
+// methods 生成认证方法，返回的 cleanup 用于在握手完成后释放 ssh-agent 连接
+func (a Auth) methods() ([]ssh.AuthMethod, func(), error) {
+	var (
+		signers []ssh.Signer
+		methods []ssh.AuthMethod
+		cleanup = func() {}
+	)
+
+	// 证书需要放在私钥前面，服务端优先校验证书
+	if a.KeyFile != "" {
+		keySigners, err := loadKeySigners(a.KeyFile, a.Passphrase, a.CertFile)
+		if err != nil {
+			return nil, cleanup, err
+		}
+		signers = append(signers, keySigners...)
+	}
+
+	if a.Agent {
+		agentClient, conn, err := dialAgent()
+		if err != nil {
+			return nil, cleanup, err
+		}
+		cleanup = func() { conn.Close() }
+
+		// 私钥与 ssh-agent 合并为一个公钥认证方法，每种认证方法只会尝试一次
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			agentSigners, err := agentClient.Signers()
+			if err != nil {
+				return nil, err
+			}
+			return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+		}))
+	} else if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if a.Password != "" {
+		methods = append(methods, ssh.Password(a.Password))
+	}
+
+	if a.KeyboardInteractive {
+		methods = append(methods, ssh.KeyboardInteractive(a.challenge))
+	}
+
+	if len(methods) == 0 {
+		cleanup()
+		return nil, func() {}, errors.New("no ssh authentication method configured")
+	}
+
+	return methods, cleanup, nil
+}
+
+// challenge 键盘交互认证，回显的问题无法自动应答，其余问题统一使用密码应答
+func (a Auth) challenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	answers := make([]string, len(questions))
+	for i := range questions {
+		if echos[i] {
+			return nil, fmt.Errorf("unsupported keyboard-interactive question: %s", questions[i])
+		}
+		answers[i] = a.Password
+	}
+
+	return answers, nil
+}
+
+// loadKeySigners 加载私钥，如果配置了证书则额外生成证书签名
+func loadKeySigners(keyFile, passphrase, certFile string) ([]ssh.Signer, error) {
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("private key %s is encrypted, passphrase is required", keyFile)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", keyFile, err)
+	}
+
+	if certFile == "" {
+		return []ssh.Signer{signer}, nil
+	}
+
+	certBytes, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate %s: %w", certFile, err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an OpenSSH certificate", certFile)
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ssh.Signer{certSigner, signer}, nil
+}
+
+func dialAgent() (agent.ExtendedAgent, net.Conn, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil, errors.New("SSH_AUTH_SOCK is not set")
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect ssh-agent: %w", err)
+	}
+
+	return agent.NewClient(conn), conn, nil
+}
@@ -0,0 +1,178 @@
+package sshx_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDialAuth(t *testing.T) {
+	dir := t.TempDir()
+	key := writeKey(t, filepath.Join(dir, "id_key"), "")
+	encrypted := writeKey(t, filepath.Join(dir, "id_encrypted"), "secret")
+
+	// 证书对应的私钥本身不在授权列表中，只能通过证书认证
+	ca, otherCA := newSigner(t), newSigner(t)
+	certKey := writeKey(t, filepath.Join(dir, "id_cert"), "")
+	for file, signer := range map[string]ssh.Signer{"id_cert-cert.pub": ca, "id_cert-other.pub": otherCA} {
+		cert := &ssh.Certificate{
+			Key:             certKey.PublicKey(),
+			CertType:        ssh.UserCert,
+			KeyId:           "alice",
+			ValidPrincipals: []string{"alice"},
+			ValidBefore:     ssh.CertTimeInfinity,
+		}
+		if err := cert.SignCert(rand.Reader, signer); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), ssh.MarshalAuthorizedKey(cert), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, agentKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentSigner, err := ssh.NewSignerFromKey(agentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveAgent(t, filepath.Join(dir, "agent.sock"), agent.AddedKey{PrivateKey: agentKey})
+
+	authorized := map[string]bool{}
+	for _, signer := range []ssh.Signer{key, encrypted, agentSigner} {
+		authorized[ssh.FingerprintSHA256(signer.PublicKey())] = true
+	}
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.PublicKey().Marshal())
+		},
+		UserKeyFallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized[ssh.FingerprintSHA256(key)] {
+				return nil, nil
+			}
+			return nil, errors.New("unknown public key")
+		},
+	}
+	password := newTestServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: checker.Authenticate,
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "123456" {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	})
+	// 只支持键盘交互认证，所有问题都需要回答密码
+	interactive := newTestServer(t, &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: ", "Verification code: "}, []bool{false, false})
+			if err != nil {
+				return nil, err
+			}
+			for _, answer := range answers {
+				if answer != "123456" {
+					return nil, errors.New("wrong answer")
+				}
+			}
+			return nil, nil
+		},
+	})
+
+	for _, tc := range []struct {
+		name   string
+		server *testServer
+		auth   sshx.Auth
+		// wantErr 为空表示认证成功
+		wantErr string
+	}{
+		{name: "password", server: password, auth: sshx.Auth{Password: "123456"}},
+		{name: "wrong password", server: password, auth: sshx.Auth{Password: "654321"}, wantErr: "unable to authenticate"},
+		{name: "key", server: password, auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_key")}},
+		{name: "encrypted key", server: password, auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_encrypted"), Passphrase: "secret"}},
+		{name: "missing passphrase", server: password, auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_encrypted")}, wantErr: "passphrase is required"},
+		{name: "wrong passphrase", server: password, auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_encrypted"), Passphrase: "wrong"},
+			wantErr: "parse private key"},
+		{name: "certificate", server: password,
+			auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_cert"), CertFile: filepath.Join(dir, "id_cert-cert.pub")}},
+		{name: "key without certificate", server: password, auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_cert")},
+			wantErr: "unable to authenticate"},
+		{name: "untrusted certificate", server: password,
+			auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_cert"), CertFile: filepath.Join(dir, "id_cert-other.pub")}, wantErr: "unable to authenticate"},
+		{name: "certificate is not a certificate", server: password,
+			auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_cert"), CertFile: filepath.Join(dir, "id_key")}, wantErr: "parse certificate"},
+		{name: "agent", server: password, auth: sshx.Auth{Agent: true}},
+		// 私钥不在授权列表中，合并 ssh-agent 中的密钥后仍然可以认证
+		{name: "key and agent", server: password, auth: sshx.Auth{KeyFile: filepath.Join(dir, "id_cert"), Agent: true}},
+		{name: "keyboard interactive", server: interactive, auth: sshx.Auth{Password: "123456", KeyboardInteractive: true}},
+		{name: "keyboard interactive wrong answer", server: interactive, auth: sshx.Auth{Password: "654321", KeyboardInteractive: true},
+			wantErr: "unable to authenticate"},
+		{name: "password only", server: interactive, auth: sshx.Auth{Password: "123456"}, wantErr: "unable to authenticate"},
+		{name: "no method", server: password, wantErr: "no ssh authentication method configured"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := sshx.Dial(sshx.Config{
+				Host:    tc.server.addr,
+				User:    "alice",
+				Auth:    tc.auth,
+				HostKey: sshx.HostKeyPolicy{Fingerprint: ssh.FingerprintSHA256(tc.server.hostKey)},
+			})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Dial: %v", err)
+				}
+				_ = client.Close()
+				return
+			}
+
+			if err == nil {
+				_ = client.Close()
+				t.Fatalf("Dial should fail with %q", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Dial = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// serveAgent 在 sock 上启动 ssh-agent 并设置 SSH_AUTH_SOCK
+func serveAgent(t *testing.T, sock string, keys ...agent.AddedKey) {
+	t.Helper()
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listener, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+}
@@ -0,0 +1,59 @@
+package sshx
+
+import (
+	"golang.org/x/crypto/ssh"
+	"time"
+)
+
+// Config SSH 连接配置
+type Config struct {
+	Host    string
+	User    string
+	Auth    Auth
+	HostKey HostKeyPolicy
+	// KeepaliveInterval 心跳间隔，为 0 时使用默认的 30s，小于 0 时关闭心跳
+	KeepaliveInterval time.Duration
+	// MaxBackoff 断线重连的最大退避时间，为 0 时使用默认的 1m
+	MaxBackoff time.Duration
+}
+
+// Dial 连接到 SSH 服务器
+func Dial(cfg Config) (*ssh.Client, error) {
+	methods, cleanup, err := cfg.Auth.methods()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	hostKeyCallback, err := cfg.HostKey.callback()
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建 SSH 客户端配置
+	config := &ssh.ClientConfig{
+		User:              cfg.User,
+		Auth:              methods,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: cfg.HostKey.algorithms(cfg.Host),
+		Timeout:           10 * time.Second,
+	}
+
+	return ssh.Dial("tcp", cfg.Host, config)
+}
+
+func (c Config) keepaliveInterval() time.Duration {
+	if c.KeepaliveInterval == 0 {
+		return defaultKeepaliveInterval
+	}
+
+	return c.KeepaliveInterval
+}
+
+func (c Config) maxBackoff() time.Duration {
+	if c.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+
+	return c.MaxBackoff
+}
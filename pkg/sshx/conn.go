@@ -0,0 +1,265 @@
+package sshx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultKeepaliveInterval = 30 * time.Second
+	minBackoff               = time.Second
+	defaultMaxBackoff        = time.Minute
+)
+
+var ErrConnClosed = errors.New("ssh connection is closed")
+
+type State string
+
+const (
+	StateConnected    State = "connected"
+	StateReconnecting State = "reconnecting"
+	StateClosed       State = "closed"
+)
+
+// Status 连接状态
+type Status struct {
+	State State `json:"state"`
+	// ConnectedAt 最近一次建立连接的时间
+	ConnectedAt int64 `json:"connected_at"`
+	// Reconnects 断线后重新连接成功的次数
+	Reconnects int `json:"reconnects"`
+	// LastError 最近一次断线或重连失败的原因
+	LastError string `json:"last_error,omitempty"`
+	// NextRetry 重连失败后，下一次允许重连的时间
+	NextRetry int64 `json:"next_retry,omitempty"`
+}
+
+// Conn 维持 SSH 以及 SFTP 连接，定期发送心跳探测
+// 连接断开后不主动重连，在下一次获取客户端时按照指数退避重新建立
+type Conn struct {
+	cfg Config
+
+	mu      sync.Mutex
+	client  *ssh.Client
+	sftp    *sftp.Client
+	status  Status
+	backoff time.Duration
+	retryAt time.Time
+	// dialing 正在重新连接时不为 nil，重连结束后关闭
+	dialing chan struct{}
+	done    chan struct{}
+}
+
+// NewConn 建立连接，首次连接失败直接返回错误
+func NewConn(cfg Config) (*Conn, error) {
+	client, sftpClient, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{
+		cfg:  cfg,
+		done: make(chan struct{}),
+	}
+	c.attach(client, sftpClient)
+	return c, nil
+}
+
+// SFTP 获取 SFTP 客户端，连接已断开时尝试重新连接
+func (c *Conn) SFTP() (*sftp.Client, error) {
+	_, sftpClient, err := c.ensure()
+	return sftpClient, err
+}
+
+// SSH 获取 SSH 客户端，连接已断开时尝试重新连接
+func (c *Conn) SSH() (*ssh.Client, error) {
+	client, _, err := c.ensure()
+	return client, err
+}
+
+// Status 当前连接状态
+func (c *Conn) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.status
+}
+
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status.State == StateClosed {
+		return nil
+	}
+	c.status.State = StateClosed
+	close(c.done)
+
+	return c.release()
+}
+
+// ensure 重连期间不持有锁，避免阻塞 Status 以及 Close，其他请求会等待本次重连的结果
+func (c *Conn) ensure() (*ssh.Client, *sftp.Client, error) {
+	c.mu.Lock()
+	for c.dialing != nil {
+		dialing := c.dialing
+		c.mu.Unlock()
+		<-dialing
+		c.mu.Lock()
+	}
+
+	switch {
+	case c.status.State == StateClosed:
+		c.mu.Unlock()
+		return nil, nil, ErrConnClosed
+	case c.sftp != nil:
+		client, sftpClient := c.client, c.sftp
+		c.mu.Unlock()
+		return client, sftpClient, nil
+	}
+
+	if wait := time.Until(c.retryAt); wait > 0 {
+		err := fmt.Errorf("ssh connection lost, retry in %s: %s", wait.Round(time.Second), c.status.LastError)
+		c.mu.Unlock()
+		return nil, nil, err
+	}
+
+	dialing := make(chan struct{})
+	c.dialing = dialing
+	c.mu.Unlock()
+
+	client, sftpClient, err := dial(c.cfg)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dialing = nil
+	close(dialing)
+
+	// 重连期间连接已经被关闭，丢弃新建立的连接
+	if c.status.State == StateClosed {
+		if err == nil {
+			_ = errors.Join(sftpClient.Close(), client.Close())
+		}
+		return nil, nil, ErrConnClosed
+	}
+
+	if err != nil {
+		c.backoff = min(max(c.backoff*2, minBackoff), c.cfg.maxBackoff())
+		c.retryAt = time.Now().Add(c.backoff)
+		c.status.LastError = err.Error()
+		c.status.NextRetry = c.retryAt.Unix()
+		return nil, nil, fmt.Errorf("reconnect ssh %s: %w", c.cfg.Host, err)
+	}
+
+	c.attach(client, sftpClient)
+	c.status.Reconnects++
+	slog.Info("SSH 重新连接成功", slog.String("host", c.cfg.Host), slog.Int("reconnects", c.status.Reconnects))
+	return client, sftpClient, nil
+}
+
+// attach 使用新建立的连接并开始监听断线，调用方需要持有锁
+func (c *Conn) attach(client *ssh.Client, sftpClient *sftp.Client) {
+	c.client, c.sftp = client, sftpClient
+	c.backoff, c.retryAt = 0, time.Time{}
+	c.status = Status{
+		State:       StateConnected,
+		ConnectedAt: time.Now().Unix(),
+		Reconnects:  c.status.Reconnects,
+	}
+
+	go c.watch(client, sftpClient)
+}
+
+// watch 等待连接断开或心跳失败，将连接标记为需要重连
+func (c *Conn) watch(client *ssh.Client, sftpClient *sftp.Client) {
+	dead := make(chan error, 2)
+	go func() { dead <- client.Wait() }()
+	go func() { dead <- sftpClient.Wait() }()
+
+	var tick <-chan time.Time
+	if interval := c.cfg.keepaliveInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case err := <-dead:
+			c.drop(client, err)
+			return
+		case <-tick:
+			if err := keepalive(client, c.cfg.keepaliveInterval()); err != nil {
+				c.drop(client, err)
+				return
+			}
+		}
+	}
+}
+
+// drop 释放已经断开的连接，只处理当前正在使用的连接
+func (c *Conn) drop(client *ssh.Client, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != client || c.status.State == StateClosed {
+		return
+	}
+
+	if err == nil {
+		err = errors.New("connection closed")
+	}
+	slog.Error("SSH 连接断开", slog.String("host", c.cfg.Host), slog.Any("err", err))
+
+	_ = c.release()
+	c.status.State = StateReconnecting
+	c.status.LastError = err.Error()
+}
+
+func (c *Conn) release() error {
+	if c.client == nil {
+		return nil
+	}
+
+	err := errors.Join(c.sftp.Close(), c.client.Close())
+	c.client, c.sftp = nil, nil
+	return err
+}
+
+func dial(cfg Config) (*ssh.Client, *sftp.Client, error) {
+	client, err := Dial(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+
+	return client, sftpClient, nil
+}
+
+// keepalive 发送 keepalive@openssh.com 请求，服务端返回任何应答都说明连接正常
+func keepalive(client *ssh.Client, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("keepalive timeout after %s", timeout)
+	}
+}
@@ -0,0 +1,163 @@
+package sshx_test
+
+import (
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnReconnect(t *testing.T) {
+	server := newTestServer(t, passwordConfig())
+	conn := newConn(t, server, -1)
+
+	if _, err := sftpWd(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	server.dropAll()
+	status := waitState(t, conn, sshx.StateReconnecting)
+	if status.LastError == "" {
+		t.Error("LastError should record why the connection was dropped")
+	}
+
+	if _, err := sftpWd(conn); err != nil {
+		t.Fatalf("SFTP after drop: %v", err)
+	}
+	if status = conn.Status(); status.State != sshx.StateConnected || status.Reconnects != 1 || status.LastError != "" {
+		t.Errorf("Status = %+v, want connected after one reconnect", status)
+	}
+}
+
+func TestConnKeepaliveTimeout(t *testing.T) {
+	server := newTestServer(t, passwordConfig())
+	server.setIgnoreKeepalive(true)
+	conn := newConn(t, server, 50*time.Millisecond)
+
+	status := waitState(t, conn, sshx.StateReconnecting)
+	if !strings.Contains(status.LastError, "keepalive timeout") {
+		t.Errorf("LastError = %q, want keepalive timeout", status.LastError)
+	}
+
+	// 服务端恢复应答心跳后重新连接
+	server.setIgnoreKeepalive(false)
+	if _, err := sftpWd(conn); err != nil {
+		t.Fatalf("SFTP after keepalive timeout: %v", err)
+	}
+	if status = conn.Status(); status.State != sshx.StateConnected || status.Reconnects != 1 {
+		t.Errorf("Status = %+v, want connected after one reconnect", status)
+	}
+}
+
+// TestConnReconnectStalled 服务端在握手阶段没有响应时，Status 以及 Close 不能被正在进行的重连阻塞
+func TestConnReconnectStalled(t *testing.T) {
+	server := newTestServer(t, passwordConfig())
+	conn := newConn(t, server, -1)
+
+	server.dropAll()
+	waitState(t, conn, sshx.StateReconnecting)
+
+	server.setStall(true)
+	for len(server.accepted) > 0 {
+		<-server.accepted
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := conn.SFTP()
+		errs <- err
+	}()
+	select {
+	case <-server.accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconnect did not reach the server")
+	}
+	// 第二个请求等待正在进行的重连，不会重复连接
+	go func() {
+		_, err := conn.SSH()
+		errs <- err
+	}()
+
+	if status := withTimeout(t, conn.Status); status.State != sshx.StateReconnecting {
+		t.Errorf("Status = %+v, want reconnecting", status)
+	}
+	if err := withTimeout(t, conn.Close); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	// 断开没有响应的连接，重连失败后两个请求都返回连接已关闭
+	server.dropAll()
+	for range 2 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, sshx.ErrConnClosed) {
+				t.Errorf("err = %v, want ErrConnClosed", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("request did not return after the connection was closed")
+		}
+	}
+	if len(server.accepted) > 0 {
+		t.Error("waiting request should not dial again")
+	}
+	if status := conn.Status(); status.State != sshx.StateClosed {
+		t.Errorf("Status = %+v, want closed", status)
+	}
+}
+
+func newConn(t *testing.T, server *testServer, keepalive time.Duration) *sshx.Conn {
+	t.Helper()
+	conn, err := sshx.NewConn(sshx.Config{
+		Host:              server.addr,
+		User:              "alice",
+		Auth:              sshx.Auth{Password: "123456"},
+		HostKey:           sshx.HostKeyPolicy{Fingerprint: ssh.FingerprintSHA256(server.hostKey)},
+		KeepaliveInterval: keepalive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func sftpWd(conn *sshx.Conn) (string, error) {
+	client, err := conn.SFTP()
+	if err != nil {
+		return "", err
+	}
+	return client.Getwd()
+}
+
+// waitState 等待连接进入 state 状态
+func waitState(t *testing.T, conn *sshx.Conn, state sshx.State) sshx.Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := conn.Status()
+		if status.State == state {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Status = %+v, want %s", status, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// withTimeout 调用 fn，1s 内没有返回时测试失败
+func withTimeout[T any](t *testing.T, fn func() T) T {
+	t.Helper()
+	result := make(chan T, 1)
+	go func() { result <- fn() }()
+
+	select {
+	case v := <-result:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("call blocked by the reconnect in progress")
+		return *new(T)
+	}
+}
@@ -0,0 +1,189 @@
+package sshx
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// HostKeyPolicy 主机密钥校验策略，优先使用固定指纹，其次使用 known_hosts 文件
+// 默认不配置任何策略时拒绝连接
+type HostKeyPolicy struct {
+	// KnownHosts known_hosts 文件路径
+	KnownHosts string
+	// Fingerprint 固定的主机密钥 SHA256 指纹，例如 SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8
+	Fingerprint string
+	// TrustOnFirstUse 首次连接未知主机时自动记录主机密钥，已记录的主机密钥不一致时仍然拒绝连接
+	TrustOnFirstUse bool
+	// Insecure 跳过主机密钥校验，不推荐在生产环境中使用
+	Insecure bool
+}
+
+// HostKeyError 主机密钥校验失败
+type HostKeyError struct {
+	Host string `json:"host"`
+	// Fingerprint 服务端提供的主机密钥指纹
+	Fingerprint string `json:"fingerprint"`
+	// Want 期望的主机密钥指纹，为空表示主机未知
+	Want []string `json:"want"`
+}
+
+func (e *HostKeyError) Error() string {
+	if len(e.Want) == 0 {
+		return fmt.Sprintf("host key verification failed: %s is unknown, fingerprint %s", e.Host, e.Fingerprint)
+	}
+
+	return fmt.Sprintf("host key verification failed: %s presented %s, expected %s, possible man-in-the-middle attack",
+		e.Host, e.Fingerprint, strings.Join(e.Want, ", "))
+}
+
+// Mismatch 主机密钥与记录的不一致
+func (e *HostKeyError) Mismatch() bool {
+	return len(e.Want) > 0
+}
+
+// knownHostsMu 首次信任时串行写入 known_hosts 文件
+var knownHostsMu sync.Mutex
+
+func (p HostKeyPolicy) callback() (ssh.HostKeyCallback, error) {
+	switch {
+	case p.Insecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	case p.Fingerprint != "":
+		return p.fingerprintCallback(), nil
+	case p.KnownHosts != "":
+		return p.knownHostsCallback()
+	default:
+		return nil, errors.New("host key verification is not configured")
+	}
+}
+
+func (p HostKeyPolicy) fingerprintCallback() ssh.HostKeyCallback {
+	want := p.Fingerprint
+	if !strings.HasPrefix(want, "SHA256:") {
+		want = "SHA256:" + want
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if got := ssh.FingerprintSHA256(key); got != want {
+			return &HostKeyError{Host: hostname, Fingerprint: got, Want: []string{want}}
+		}
+		return nil
+	}
+}
+
+func (p HostKeyPolicy) knownHostsCallback() (ssh.HostKeyCallback, error) {
+	if p.TrustOnFirstUse {
+		if err := ensureFile(p.KnownHosts); err != nil {
+			return nil, err
+		}
+	}
+
+	cb, err := knownhosts.New(p.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		// 主机已记录但密钥不一致，无论是否首次信任都必须拒绝
+		if len(keyErr.Want) > 0 {
+			want := make([]string, 0, len(keyErr.Want))
+			for _, known := range keyErr.Want {
+				want = append(want, ssh.FingerprintSHA256(known.Key))
+			}
+			return &HostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key), Want: want}
+		}
+
+		if !p.TrustOnFirstUse {
+			return &HostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key)}
+		}
+
+		return appendKnownHost(p.KnownHosts, hostname, remote, key)
+	}, nil
+}
+
+// algorithms 根据 known_hosts 中已记录的密钥类型协商主机密钥算法
+// 避免服务端优先提供其他类型的密钥导致校验失败
+func (p HostKeyPolicy) algorithms(host string) []string {
+	if p.Insecure || p.Fingerprint != "" || p.KnownHosts == "" {
+		return nil
+	}
+
+	cb, err := knownhosts.New(p.KnownHosts)
+	if err != nil {
+		return nil
+	}
+
+	// 使用随机密钥探测，KeyError 中会返回该主机所有已记录的密钥
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(cb(host, &net.TCPAddr{}, probe), &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, known := range keyErr.Want {
+		switch known.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, known.Key.Type())
+		}
+	}
+
+	return algos
+}
+
+func appendKnownHost(file, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if remote != nil && remote.String() != hostname {
+		addresses = append(addresses, knownhosts.Normalize(remote.String()))
+	}
+
+	_, err = fmt.Fprintln(f, knownhosts.Line(addresses, key))
+	return err
+}
+
+func ensureFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
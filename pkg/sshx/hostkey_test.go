@@ -0,0 +1,181 @@
+package sshx_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDialHostKey(t *testing.T) {
+	server := newTestServer(t, passwordConfig())
+	known := filepath.Join(t.TempDir(), "known_hosts")
+	other := newSigner(t)
+
+	for _, tc := range []struct {
+		name string
+		// line known_hosts 中记录的主机密钥，为空表示主机未知
+		line   ssh.PublicKey
+		policy sshx.HostKeyPolicy
+		// mismatch 为 nil 表示校验通过
+		mismatch *bool
+	}{
+		{name: "known host", line: server.hostKey, policy: sshx.HostKeyPolicy{KnownHosts: known}},
+		{name: "changed host key", line: other.PublicKey(), policy: sshx.HostKeyPolicy{KnownHosts: known}, mismatch: ptr(true)},
+		// 首次信任不能覆盖已记录的主机密钥
+		{name: "changed host key with tofu", line: other.PublicKey(),
+			policy: sshx.HostKeyPolicy{KnownHosts: known, TrustOnFirstUse: true}, mismatch: ptr(true)},
+		{name: "unknown host", policy: sshx.HostKeyPolicy{KnownHosts: known}, mismatch: ptr(false)},
+		{name: "fingerprint", policy: sshx.HostKeyPolicy{Fingerprint: ssh.FingerprintSHA256(server.hostKey)}},
+		{name: "fingerprint without prefix",
+			policy: sshx.HostKeyPolicy{Fingerprint: strings.TrimPrefix(ssh.FingerprintSHA256(server.hostKey), "SHA256:")}},
+		{name: "fingerprint mismatch", policy: sshx.HostKeyPolicy{Fingerprint: ssh.FingerprintSHA256(other.PublicKey())},
+			mismatch: ptr(true)},
+		{name: "insecure", policy: sshx.HostKeyPolicy{Insecure: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			writeKnownHosts(t, known, server.addr, tc.line)
+			err := dial(server, tc.policy)
+			if tc.mismatch == nil {
+				if err != nil {
+					t.Fatalf("Dial: %v", err)
+				}
+				return
+			}
+
+			var hostKeyErr *sshx.HostKeyError
+			if !errors.As(err, &hostKeyErr) {
+				t.Fatalf("Dial = %v, want HostKeyError", err)
+			}
+			if hostKeyErr.Mismatch() != *tc.mismatch {
+				t.Errorf("Mismatch = %v, want %v", hostKeyErr.Mismatch(), *tc.mismatch)
+			}
+			if hostKeyErr.Fingerprint != ssh.FingerprintSHA256(server.hostKey) {
+				t.Errorf("Fingerprint = %s, want the server host key", hostKeyErr.Fingerprint)
+			}
+		})
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		if err := dial(server, sshx.HostKeyPolicy{}); err == nil {
+			t.Fatal("Dial without host key policy should fail")
+		}
+	})
+}
+
+func TestDialTrustOnFirstUse(t *testing.T) {
+	server := newTestServer(t, passwordConfig())
+	known := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	policy := sshx.HostKeyPolicy{KnownHosts: known, TrustOnFirstUse: true}
+
+	if err := dial(server, policy); err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+
+	data, err := os.ReadFile(known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey); strings.TrimSpace(string(data)) != want {
+		t.Fatalf("known_hosts = %q, want %q", data, want)
+	}
+
+	// 记录之后仍然可以连接，并且不会重复记录
+	if err = dial(server, policy); err != nil {
+		t.Fatalf("second Dial: %v", err)
+	}
+	if again, _ := os.ReadFile(known); string(again) != string(data) {
+		t.Errorf("known_hosts changed to %q", again)
+	}
+
+	// 服务端更换主机密钥后拒绝连接
+	recorded := server.hostKey
+	server.setConfig(passwordConfig(), newSigner(t))
+	err = dial(server, policy)
+	var hostKeyErr *sshx.HostKeyError
+	if !errors.As(err, &hostKeyErr) || !hostKeyErr.Mismatch() {
+		t.Fatalf("Dial after host key change = %v, want mismatch", err)
+	}
+	if len(hostKeyErr.Want) != 1 || hostKeyErr.Want[0] != ssh.FingerprintSHA256(recorded) {
+		t.Errorf("Want = %v, want the recorded key %s", hostKeyErr.Want, ssh.FingerprintSHA256(recorded))
+	}
+}
+
+// TestDialHostKeyAlgorithms 服务端同时提供 ECDSA 和 RSA 主机密钥，known_hosts 中只记录了 RSA 密钥
+// 默认的算法顺序下会优先协商 ECDSA
+func TestDialHostKeyAlgorithms(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ssh.NewSignerFromKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t, passwordConfig())
+	server.setConfig(passwordConfig(), ecdsaKey, rsaKey)
+	known := filepath.Join(t.TempDir(), "known_hosts")
+	writeKnownHosts(t, known, server.addr, rsaKey.PublicKey())
+
+	if err = dial(server, sshx.HostKeyPolicy{KnownHosts: known}); err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+}
+
+func passwordConfig() *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "123456" {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+}
+
+func dial(server *testServer, policy sshx.HostKeyPolicy) error {
+	client, err := sshx.Dial(sshx.Config{
+		Host:    server.addr,
+		User:    "alice",
+		Auth:    sshx.Auth{Password: "123456"},
+		HostKey: policy,
+	})
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+// writeKnownHosts 覆盖 known_hosts 文件，key 为 nil 时写入空文件
+func writeKnownHosts(t *testing.T, file, addr string, key ssh.PublicKey) {
+	t.Helper()
+	var data []byte
+	if key != nil {
+		data = []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n")
+	}
+
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,198 @@
+package sshx_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"os"
+	"sync"
+	"testing"
+)
+
+// testServer 进程内的 SSH 服务端，支持 sftp 子系统，用于测试认证、主机密钥校验以及断线重连
+type testServer struct {
+	addr     string
+	listener net.Listener
+	// hostKey 最近一次 setConfig 使用的主机密钥
+	hostKey ssh.PublicKey
+
+	mu     sync.Mutex
+	config *ssh.ServerConfig
+	conns  []net.Conn
+	// stall 为 true 时接受 TCP 连接但不进行握手，模拟没有响应的服务端
+	stall bool
+	// ignoreKeepalive 为 true 时不应答心跳请求
+	ignoreKeepalive bool
+	// accepted 每接受一个 TCP 连接发送一次
+	accepted chan struct{}
+}
+
+// newTestServer 生成一个 ed25519 主机密钥
+func newTestServer(t *testing.T, config *ssh.ServerConfig) *testServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{addr: listener.Addr().String(), listener: listener, accepted: make(chan struct{}, 16)}
+	s.setConfig(config, newSigner(t))
+	go s.serve()
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.dropAll()
+	})
+	return s
+}
+
+// setConfig 替换之后的连接使用的配置以及主机密钥，至少需要一个主机密钥
+func (s *testServer) setConfig(config *ssh.ServerConfig, hostKeys ...ssh.Signer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range hostKeys {
+		config.AddHostKey(key)
+	}
+	s.config, s.hostKey = config, hostKeys[0].PublicKey()
+}
+
+func (s *testServer) setStall(stall bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stall = stall
+}
+
+func (s *testServer) setIgnoreKeepalive(ignore bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignoreKeepalive = ignore
+}
+
+// dropAll 断开所有已经建立的连接
+func (s *testServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		config, stall, ignoreKeepalive := s.config, s.stall, s.ignoreKeepalive
+		s.mu.Unlock()
+
+		select {
+		case s.accepted <- struct{}{}:
+		default:
+		}
+
+		if !stall {
+			go handleConn(conn, config, ignoreKeepalive)
+		}
+	}
+}
+
+func handleConn(conn net.Conn, config *ssh.ServerConfig, ignoreKeepalive bool) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	go func() {
+		for req := range reqs {
+			if req.WantReply && !ignoreKeepalive {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					_ = channel.Close()
+					return
+				}
+				go func() {
+					_ = server.Serve()
+					_ = channel.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// writeKey 生成 ed25519 私钥以 OpenSSH 格式写入 file，passphrase 不为空时加密
+func writeKey(t *testing.T, file, passphrase string) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "test")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "test", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
@@ -1,71 +1,250 @@
 package web
 
 import (
-	"errors"
 	"fmt"
 	"github.com/Duke1616/vuefinder-go/pkg/finder"
 	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/Duke1616/vuefinder-go/pkg/policy"
+	"github.com/Duke1616/vuefinder-go/pkg/session"
 	"github.com/ecodeclub/ekit/slice"
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"io/fs"
 	"path"
 	"strconv"
+	"strings"
 )
 
 type Handler struct {
-	finders map[int64]finder.Finder
+	sessions *session.Manager
+	// policy 为 nil 时不做授权检查
+	policy *policy.Policy
 }
 
-func NewHandler() *Handler {
+func NewHandler(sessions *session.Manager) *Handler {
 	return &Handler{
-		finders: make(map[int64]finder.Finder),
+		sessions: sessions,
+	}
+}
+
+// NewHandlerWithPolicy 每个操作执行前按照调用方检查路径权限，列表中隐藏没有权限的文件
+func NewHandlerWithPolicy(sessions *session.Manager, p *policy.Policy) *Handler {
+	return &Handler{
+		sessions: sessions,
+		policy:   p,
 	}
 }
 
 func (h *Handler) RegisterRoutes(server *gin.Engine) {
 	g := server.Group("/api/finder")
 
+	// VueFinder 原生协议，通过 q 参数区分操作，前端只需要配置 baseUrl
+	g.GET("", ginx.Dispatch("q", map[string]gin.HandlerFunc{
+		"index":        ginx.Wrap(h.Index),
+		"subfolders":   ginx.Wrap(h.Subfolders),
+		"download":     ginx.WrapStream(h.Download),
+		"preview":      ginx.WrapStream(h.Preview),
+		"search":       ginx.Wrap(h.Search),
+		"grep":         ginx.Wrap(h.Grep),
+		"capabilities": ginx.Wrap(h.Capabilities),
+	}))
+	g.POST("", ginx.Dispatch("q", map[string]gin.HandlerFunc{
+		"upload":    ginx.Wrap(h.Upload),
+		"newfile":   ginx.WrapBody(h.NewFile),
+		"newfolder": ginx.WrapBody(h.NewFolder),
+		"rename":    ginx.WrapBody(h.Rename),
+		"move":      ginx.WrapBody(h.Move),
+		"copy":      ginx.WrapBody(h.Copy),
+		"transfer":  ginx.WrapBody(h.Transfer),
+		"chmod":     ginx.WrapBody(h.Chmod),
+		"chown":     ginx.WrapBody(h.Chown),
+		"delete":    ginx.WrapBody(h.Remove),
+		"archive":   ginx.WrapBody(h.Archive),
+		"unarchive": ginx.WrapBody(h.Unarchive),
+		"save":      ginx.WrapBuffBody(h.Save),
+	}))
+
 	g.GET("/index", ginx.Wrap(h.Index))
 	g.GET("/subfolders", ginx.Wrap(h.Subfolders))
-	g.GET("/download", ginx.WrapData(h.Download))
+	g.GET("/download", ginx.WrapStream(h.Download))
 	g.GET("/search", ginx.Wrap(h.Search))
-	g.GET("/preview", ginx.WrapBuff(h.Preview))
+	g.GET("/grep", ginx.Wrap(h.Grep))
+	g.GET("/capabilities", ginx.Wrap(h.Capabilities))
+	g.GET("/preview", ginx.WrapStream(h.Preview))
 	g.POST("/upload", ginx.Wrap(h.Upload))
 	g.POST("/new_folder", ginx.WrapBody(h.NewFolder))
 	g.POST("/new_file", ginx.WrapBody(h.NewFile))
 	g.POST("/rename", ginx.WrapBody(h.Rename))
 	g.POST("/remove", ginx.WrapBody(h.Remove))
 	g.POST("/move", ginx.WrapBody(h.Move))
+	g.POST("/copy", ginx.WrapBody(h.Copy))
+	g.POST("/transfer", ginx.WrapBody(h.Transfer))
+	g.POST("/chmod", ginx.WrapBody(h.Chmod))
+	g.POST("/chown", ginx.WrapBody(h.Chown))
 	g.POST("/archive", ginx.WrapBody(h.Archive))
+	g.POST("/unarchive", ginx.WrapBody(h.Unarchive))
 	g.POST("/save", ginx.WrapBuffBody(h.Save))
 }
 
-func (h *Handler) SetFinder(id int64, f finder.Finder) {
-	h.finders[id] = f
-}
-
-func (h *Handler) getFinder(ctx *gin.Context) (finder.Finder, error) {
+func (h *Handler) getFinder(ctx *gin.Context) (int64, finder.Finder, error) {
 	queryId := ctx.Query("id")
 	id, err := strconv.ParseInt(queryId, 10, 64)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
+	}
+
+	fd, err := h.sessions.Finder(id)
+	return id, fd, err
+}
+
+// subject 没有启用认证时为匿名调用方
+func subject(ctx *gin.Context) policy.Subject {
+	principal, _ := ginx.GetPrincipal(ctx)
+	return policy.Subject{Name: principal.Name, Groups: principal.Groups}
+}
+
+// authorize 检查会话 id 中的所有路径，没有配置策略时允许所有操作
+func (h *Handler) authorize(ctx *gin.Context, id int64, action policy.Action, files ...string) error {
+	return h.check(ctx, id, action, files, h.policy.Check)
+}
+
+// authorizeTree 删除、移动、复制、打包、解压等作用于整个目录的操作，目录下单独配置了规则的路径也需要允许
+func (h *Handler) authorizeTree(ctx *gin.Context, id int64, action policy.Action, files ...string) error {
+	return h.check(ctx, id, action, files, h.policy.CheckTree)
+}
+
+func (h *Handler) check(ctx *gin.Context, id int64, action policy.Action, files []string,
+	check func(sub policy.Subject, finder int64, action policy.Action, file string) error) error {
+	if h.policy == nil {
+		return nil
+	}
+
+	sub := subject(ctx)
+	for _, file := range files {
+		if err := check(sub, id, action, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// authorizeItems 检查文件以及目录下的所有文件，复制、移动到 target 时检查目标目录下同名的文件
+func (h *Handler) authorizeItems(ctx *gin.Context, id int64, action policy.Action, items []Item, target string) error {
+	for _, item := range items {
+		file := item.Path
+		if target != "" {
+			file = path.Join(target, path.Base(item.Path))
+		}
+
+		if err := h.authorizeTree(ctx, id, action, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// authorizeList 可以列出可读的目录，以及可读路径的上级目录
+func (h *Handler) authorizeList(ctx *gin.Context, id int64, dir string) error {
+	if h.policy == nil || h.policy.Visible(subject(ctx), id, dir) {
+		return nil
+	}
+
+	return h.authorize(ctx, id, policy.Read, dir)
+}
+
+// visible 目录本身需要可见，存储以及文件列表中只保留可见的项，上级目录 . 以及 .. 保留用于导航
+func (h *Handler) visible(ctx *gin.Context, id int64, storages finder.Storages) (finder.Storages, error) {
+	if h.policy == nil {
+		return storages, nil
+	}
+
+	if err := h.authorizeList(ctx, id, storages.Dirname); err != nil {
+		return finder.Storages{}, err
+	}
+
+	sub := subject(ctx)
+	storages.Storages = slice.FilterMap(storages.Storages, func(idx int, src string) (string, bool) {
+		return src, h.policy.Visible(sub, id, "/"+src)
+	})
+	storages.Files = h.visibleFiles(ctx, id, storages.Files)
+	return storages, nil
+}
+
+func (h *Handler) visibleFiles(ctx *gin.Context, id int64, files []finder.FileInfo) []finder.FileInfo {
+	if h.policy == nil {
+		return files
+	}
+
+	sub := subject(ctx)
+	return slice.FilterMap(files, func(idx int, src finder.FileInfo) (finder.FileInfo, bool) {
+		return src, src.Basename == "." || src.Basename == ".." || h.policy.Visible(sub, id, src.Path)
+	})
+}
+
+// index 修改完成后返回当前目录的最新列表
+func (h *Handler) index(ctx *gin.Context, id int64, fd finder.Finder, adapter, dir string) (ginx.Result, error) {
+	storage, err := fd.Index(ctx, adapter, dir)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if storage, err = h.visible(ctx, id, storage); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return ginx.Result{
+		Data: storage,
+	}, nil
+}
+
+// paginate 没有排序以及分页条件时原样返回，保持 VueFinder 默认请求的结构
+func paginate(files []finder.FileInfo, req ListReq) ([]finder.FileInfo, *finder.Pagination, error) {
+	opts := req.options()
+	if opts == (finder.ListOptions{}) {
+		return files, nil, nil
+	}
+
+	return finder.Paginate(files, opts)
+}
+
+// Capabilities 会话支持的操作，指定 path 并且配置了授权策略时，同时按照调用方在该目录下的权限计算
+func (h *Handler) Capabilities(ctx *gin.Context) (ginx.Result, error) {
+	pathQuery := ctx.Query("path")
+
+	id, fd, err := h.getFinder(ctx)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
 	}
 
-	fd, ok := h.finders[id]
-	if !ok {
-		return nil, errors.New("finder not found")
+	c := finder.CapabilitiesOf(fd)
+	if h.policy != nil && pathQuery != "" {
+		sub := subject(ctx)
+		write := h.policy.Allowed(sub, id, policy.Write, pathQuery)
+		remove := h.policy.Allowed(sub, id, policy.Delete, pathQuery)
+
+		c.Upload, c.Save, c.NewFile, c.NewFolder = c.Upload && write, c.Save && write, c.NewFile && write, c.NewFolder && write
+		c.Rename, c.Copy, c.Archive, c.Unarchive = c.Rename && write, c.Copy && write, c.Archive && write, c.Unarchive && write
+		c.Chmod, c.Chown = c.Chmod && write, c.Chown && write
+		c.Move, c.Remove = c.Move && write && remove, c.Remove && remove
 	}
 
-	return fd, nil
+	return ginx.Result{
+		Data: c,
+	}, nil
 }
 
 func (h *Handler) Save(ctx *gin.Context, req SaveReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	if err = h.authorize(ctx, id, policy.Write, pathQuery); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	err = fd.Save(ctx, pathQuery, req.Content)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
@@ -77,197 +256,423 @@ func (h *Handler) Save(ctx *gin.Context, req SaveReq) (ginx.Result, error) {
 func (h *Handler) Preview(ctx *gin.Context) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	if err = h.authorize(ctx, id, policy.Read, pathQuery); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	// 获取文件内容
-	buff, err := fd.Preview(ctx, pathQuery)
+	content, err := fd.Preview(ctx, pathQuery)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	// 根据文件类型设置响应的 Content-Type
-	contentType := http.DetectContentType(buff.Bytes())
-	ctx.Header("Content-Type", contentType)
-
-	return ginx.Result{Message: "OK", Data: buff.String()}, nil
+	// 不指定 Content-Type，根据文件类型自动识别
+	return ginx.Result{
+		Message: "OK",
+		Data:    toStream(content, ""),
+	}, nil
 }
 
 func (h *Handler) Search(ctx *gin.Context) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
-	filter := ctx.Query("filter")
 
-	fd, err := h.getFinder(ctx)
+	var (
+		req  SearchReq
+		list ListReq
+	)
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	if err := ctx.ShouldBindQuery(&list); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	storages, err := fd.Search(ctx, adapter, pathQuery, filter)
+	// 搜索会遍历整个目录，先通过 Index 解析出实际的目录并检查权限
+	dir, err := fd.Index(ctx, adapter, pathQuery)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	if err = h.authorizeList(ctx, id, dir.Dirname); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	storages, err := fd.Search(ctx, dir.Adapter, dir.Dirname, finder.SearchOptions{
+		Pattern:        req.Filter,
+		Match:          req.Match,
+		IgnoreCase:     req.IgnoreCase,
+		MaxDepth:       req.MaxDepth,
+		Type:           req.Type,
+		MinSize:        req.MinSize,
+		MaxSize:        req.MaxSize,
+		ModifiedAfter:  req.ModifiedAfter,
+		ModifiedBefore: req.ModifiedBefore,
+		Limit:          req.Limit,
+	})
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	if storages, err = h.visible(ctx, id, storages); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if storages.Files, storages.Pagination, err = paginate(storages.Files, list); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	return ginx.Result{
 		Data: storages,
 	}, nil
 }
 
+// Grep 搜索 path 下文件内容中匹配的行，返回文件路径、行号以及行内容
+func (h *Handler) Grep(ctx *gin.Context) (ginx.Result, error) {
+	pathQuery := ctx.Query("path")
+
+	var req GrepReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	id, fd, err := h.getFinder(ctx)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	// 远程主机上的 find 会把以 - 开头的路径当作表达式
+	if !path.IsAbs(pathQuery) {
+		err = fmt.Errorf("grep path %q must be absolute: %w", pathQuery, fs.ErrInvalid)
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if err = h.authorizeList(ctx, id, pathQuery); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	matches, err := fd.Grep(ctx, pathQuery, finder.GrepOptions{
+		Pattern:     req.Pattern,
+		Regex:       req.Regex,
+		IgnoreCase:  req.IgnoreCase,
+		Include:     req.Include,
+		MaxFileSize: req.MaxFileSize,
+		Limit:       req.Limit,
+	})
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if h.policy != nil {
+		sub := subject(ctx)
+		matches = slice.FilterMap(matches, func(idx int, src finder.GrepMatch) (finder.GrepMatch, bool) {
+			return src, h.policy.Allowed(sub, id, policy.Read, src.Path)
+		})
+	}
+
+	return ginx.Result{
+		Data: matches,
+	}, nil
+}
+
 func (h *Handler) Archive(ctx *gin.Context, req ArchiveReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
-	err = fd.Archive(ctx, toFinderItems(req.Items), req.Name, pathQuery)
+
+	if err = h.authorizeItems(ctx, id, policy.Read, req.Items, ""); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	// 按照格式补全后缀后的名称才是实际写入的文件
+	name, err := finder.ArchiveName(path.Join(pathQuery, req.Name), req.Format)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
+	if err = h.authorize(ctx, id, policy.Write, name); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
 
-	storage, err := fd.Index(ctx, adapter, pathQuery)
+	err = fd.Archive(ctx, toFinderItems(req.Items), req.Name, pathQuery, req.Format)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	return ginx.Result{
-		Data: storage,
-	}, nil
+	return h.index(ctx, id, fd, adapter, pathQuery)
+}
+
+func (h *Handler) Unarchive(ctx *gin.Context, req UnarchiveReq) (ginx.Result, error) {
+	pathQuery := ctx.Query("path")
+	adapter := ctx.Query("adapter")
+
+	id, fd, err := h.getFinder(ctx)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	// 与 VueFinder 保持一致，解压到当前目录下与压缩包同名的目录中
+	target := path.Join(pathQuery, finder.TrimArchiveExt(path.Base(req.Item)))
+	if err = h.authorize(ctx, id, policy.Read, req.Item); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	if err = h.authorizeTree(ctx, id, policy.Write, target); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	err = fd.Unarchive(ctx, req.Item, target, req.Conflict)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return h.index(ctx, id, fd, adapter, pathQuery)
 }
 
 func (h *Handler) Move(ctx *gin.Context, req MoveReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	// 移动相当于从原目录删除后写入目标目录
+	if err = h.authorizeItems(ctx, id, policy.Delete, req.Items, ""); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	if err = h.authorizeItems(ctx, id, policy.Write, req.Items, req.Item); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	err = fd.Move(ctx, toFinderItems(req.Items), req.Item)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return h.index(ctx, id, fd, adapter, pathQuery)
+}
+
+func (h *Handler) Copy(ctx *gin.Context, req CopyReq) (ginx.Result, error) {
+	pathQuery := ctx.Query("path")
+	adapter := ctx.Query("adapter")
 
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	storage, err := fd.Index(ctx, adapter, pathQuery)
+	if err = h.authorizeItems(ctx, id, policy.Read, req.Items, ""); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	if err = h.authorizeItems(ctx, id, policy.Write, req.Items, req.Item); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	err = fd.Copy(ctx, toFinderItems(req.Items), req.Item, req.Conflict)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	return ginx.Result{
-		Data: storage,
-	}, nil
+	return h.index(ctx, id, fd, adapter, pathQuery)
 }
 
-func (h *Handler) Remove(ctx *gin.Context, req RemoveReq) (ginx.Result, error) {
+func (h *Handler) Chmod(ctx *gin.Context, req ChmodReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	err = fd.Remove(ctx, toFinderItems(req.Items), pathQuery)
+	// 只修改文件本身时不需要检查目录下单独配置的规则
+	if req.Recursive {
+		err = h.authorizeItems(ctx, id, policy.Write, req.Items, "")
+	} else {
+		err = h.authorize(ctx, id, policy.Write, itemPaths(req.Items)...)
+	}
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	storage, err := fd.Index(ctx, adapter, pathQuery)
+	if err = fd.Chmod(ctx, toFinderItems(req.Items), req.Mode, req.Recursive); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return h.index(ctx, id, fd, adapter, pathQuery)
+}
+
+func (h *Handler) Chown(ctx *gin.Context, req ChownReq) (ginx.Result, error) {
+	pathQuery := ctx.Query("path")
+	adapter := ctx.Query("adapter")
+
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	return ginx.Result{
-		Data: storage,
-	}, nil
+	// 只修改文件本身时不需要检查目录下单独配置的规则
+	if req.Recursive {
+		err = h.authorizeItems(ctx, id, policy.Write, req.Items, "")
+	} else {
+		err = h.authorize(ctx, id, policy.Write, itemPaths(req.Items)...)
+	}
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if err = fd.Chown(ctx, toFinderItems(req.Items), req.Owner, req.Group, req.Recursive); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return h.index(ctx, id, fd, adapter, pathQuery)
 }
 
-func (h *Handler) Rename(ctx *gin.Context, req RenameReq) (ginx.Result, error) {
+// Transfer 在两个会话之间复制或移动文件，返回目标目录的最新列表
+func (h *Handler) Transfer(ctx *gin.Context, req TransferReq) (ginx.Result, error) {
+	src, err := h.sessions.Finder(req.From)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	dst, err := h.sessions.Finder(req.To)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	// 移动时源文件会被删除
+	if err = h.authorizeItems(ctx, req.From, policy.Read, req.Items, ""); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+	if req.Move {
+		if err = h.authorizeItems(ctx, req.From, policy.Delete, req.Items, ""); err != nil {
+			return ginx.Result{Message: err.Error()}, err
+		}
+	}
+	if err = h.authorizeItems(ctx, req.To, policy.Write, req.Items, req.Target); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	err = finder.Transfer(ctx, src, dst, toFinderItems(req.Items), req.Target, req.Conflict, req.Move)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	// 目标目录属于另一个会话，存储名称取目标路径的第一级目录
+	adapter, _, _ := strings.Cut(strings.TrimPrefix(req.Target, "/"), "/")
+	return h.index(ctx, req.To, dst, adapter, req.Target)
+}
+
+func (h *Handler) Remove(ctx *gin.Context, req RemoveReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	err = fd.Rename(ctx, req.Item, req.Name, pathQuery)
+	if err = h.authorizeItems(ctx, id, policy.Delete, req.Items, ""); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	err = fd.Remove(ctx, toFinderItems(req.Items), pathQuery)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	storage, err := fd.Index(ctx, adapter, pathQuery)
+	return h.index(ctx, id, fd, adapter, pathQuery)
+}
+
+func (h *Handler) Rename(ctx *gin.Context, req RenameReq) (ginx.Result, error) {
+	pathQuery := ctx.Query("path")
+	adapter := ctx.Query("adapter")
+
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	return ginx.Result{
-		Data: storage,
-	}, nil
+	// 重命名目录相当于移动其下所有文件
+	if err = h.authorizeTree(ctx, id, policy.Write, req.Item, path.Join(path.Dir(req.Item), req.Name)); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	err = fd.Rename(ctx, req.Item, req.Name, pathQuery)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return h.index(ctx, id, fd, adapter, pathQuery)
 }
 
 func (h *Handler) NewFile(ctx *gin.Context, req NewFileReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	err = fd.NewFile(ctx, pathQuery, req.Name)
-	if err != nil {
+	if err = h.authorize(ctx, id, policy.Write, path.Join(pathQuery, req.Name)); err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	storage, err := fd.Index(ctx, adapter, pathQuery)
+	err = fd.NewFile(ctx, pathQuery, req.Name)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	return ginx.Result{
-		Data: storage,
-	}, nil
+	return h.index(ctx, id, fd, adapter, pathQuery)
 }
 
 func (h *Handler) NewFolder(ctx *gin.Context, req NewFolderReq) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	err = fd.NewFolder(ctx, pathQuery, req.Name)
-	if err != nil {
+	if err = h.authorize(ctx, id, policy.Write, path.Join(pathQuery, req.Name)); err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	storage, err := fd.Index(ctx, adapter, pathQuery)
+	err = fd.NewFolder(ctx, pathQuery, req.Name)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	return ginx.Result{
-		Data: storage,
-	}, nil
+	return h.index(ctx, id, fd, adapter, pathQuery)
 }
 
 func (h *Handler) Download(ctx *gin.Context) (ginx.Result, error) {
 	file := ctx.Query("path")
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
-	buff, err := fd.Download(ctx, file)
+	if err = h.authorize(ctx, id, policy.Read, file); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	content, err := fd.Download(ctx, file)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
@@ -275,30 +680,56 @@ func (h *Handler) Download(ctx *gin.Context) (ginx.Result, error) {
 	ctx.Header("Content-Description", "File Transfer")
 	ctx.Header("Content-Transfer-Encoding", "binary")
 	ctx.Header("Content-Disposition", "attachment; filename="+path.Base(file))
-	ctx.Header("Content-Type", "application/octet-stream")
 
 	return ginx.Result{
-		Data: buff.Bytes(),
+		Data: toStream(content, "application/octet-stream"),
 	}, nil
 }
 
+// subfoldersDir 与各个 Finder 的 Subfolders 解析路径的方式一致，adapter://path 取 path，path 为空时为存储根目录
+func subfoldersDir(adapter, p string) string {
+	if !strings.Contains(p, "://") {
+		return p
+	}
+
+	if dir := strings.Split(p, "://")[1]; dir != "" {
+		return dir
+	}
+	return "/" + adapter
+}
+
 func (h *Handler) Subfolders(ctx *gin.Context) (ginx.Result, error) {
 	pathQuery := ctx.Query("path")
 	adapter := ctx.Query("adapter")
 
-	fd, err := h.getFinder(ctx)
+	var list ListReq
+	if err := ctx.ShouldBindQuery(&list); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	if err = h.authorizeList(ctx, id, subfoldersDir(adapter, pathQuery)); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	files, err := fd.Subfolders(ctx, adapter, pathQuery)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	files, page, err := paginate(h.visibleFiles(ctx, id, files), list)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	return ginx.Result{
 		Data: &RetrieveFolder{
-			Folders: files,
+			Folders:    files,
+			Pagination: page,
 		},
 	}, nil
 }
@@ -316,11 +747,15 @@ func (h *Handler) Upload(ctx *gin.Context) (ginx.Result, error) {
 
 	fmt.Printf("srcFile: %+v\n", srcFile)
 
-	fd, err := h.getFinder(ctx)
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	if err = h.authorize(ctx, id, policy.Write, path.Join(remoteDir, remoteFile)); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	err = fd.Upload(ctx, srcFile, remoteDir, remoteFile)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
@@ -335,7 +770,12 @@ func (h *Handler) Index(ctx *gin.Context) (ginx.Result, error) {
 	adapterQuery := ctx.Query("adapter")
 	pathQuery := ctx.Query("path")
 
-	fd, err := h.getFinder(ctx)
+	var list ListReq
+	if err := ctx.ShouldBindQuery(&list); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	id, fd, err := h.getFinder(ctx)
 	if err != nil {
 		return ginx.Result{Message: err.Error()}, err
 	}
@@ -345,11 +785,36 @@ func (h *Handler) Index(ctx *gin.Context) (ginx.Result, error) {
 		return ginx.Result{Message: err.Error()}, err
 	}
 
+	if data, err = h.visible(ctx, id, data); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if data.Files, data.Pagination, err = paginate(data.Files, list); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
 	return ginx.Result{
 		Data: data,
 	}, nil
 }
 
+// toStream ETag 由文件修改时间以及大小生成，文件变化后断点续传会重新下载
+func toStream(content finder.Content, contentType string) ginx.Stream {
+	return ginx.Stream{
+		ReadSeekCloser: content,
+		Name:           content.Name,
+		ModTime:        content.ModTime,
+		ETag:           fmt.Sprintf(`"%x-%x"`, content.ModTime.UnixNano(), content.Size),
+		ContentType:    contentType,
+	}
+}
+
+func itemPaths(items []Item) []string {
+	return slice.Map(items, func(idx int, src Item) string {
+		return src.Path
+	})
+}
+
 func toFinderItems(req []Item) []finder.Item {
 	return slice.Map(req, func(idx int, src Item) finder.Item {
 		return finder.Item{
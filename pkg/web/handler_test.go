@@ -0,0 +1,421 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/Duke1616/vuefinder-go/pkg/policy"
+	"github.com/Duke1616/vuefinder-go/pkg/session"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"github.com/Duke1616/vuefinder-go/pkg/web"
+	"github.com/gin-gonic/gin"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// headerAuthenticator 测试使用，X-User 为用户名，X-Groups 为逗号分隔的用户组
+type headerAuthenticator struct{}
+
+func (headerAuthenticator) Authenticate(r *http.Request) (ginx.Principal, error) {
+	user := r.Header.Get("X-User")
+	if user == "" {
+		return ginx.Principal{}, ginx.ErrNoCredentials
+	}
+
+	var groups []string
+	if g := r.Header.Get("X-Groups"); g != "" {
+		groups = strings.Split(g, ",")
+	}
+
+	return ginx.Principal{Name: user, Method: "test", Groups: groups}, nil
+}
+
+func (headerAuthenticator) Challenge() string {
+	return "Test"
+}
+
+func TestHandlerPolicy(t *testing.T) {
+	ctx := context.Background()
+	fd := finder.NewMemoryFinder()
+	for _, dir := range []string{"/srv", "/srv/app", "/srv/app/secret", "/etc"} {
+		if err := fd.NewFolder(ctx, "/", dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/srv/app/config.yaml", "/srv/app/secret/key"} {
+		if err := fd.Save(ctx, file, "data"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions := session.NewManager(nil, sshx.Config{})
+	sessions.Register(20, session.KindMemory, fd)
+
+	p, err := policy.New([]policy.Rule{
+		{Groups: []string{"ops"}, Path: "/srv/app", Allow: []policy.Action{policy.Read, policy.Write, policy.Delete}},
+		{Users: []string{"*"}, Path: "/srv", Allow: []policy.Action{policy.Read}},
+		{Users: []string{"*"}, Path: "/srv/app/secret", Allow: []policy.Action{}},
+		{Groups: []string{"ops"}, Path: "/srv/app/release.tar.gz", Allow: []policy.Action{policy.Read}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(ginx.Authenticate(headerAuthenticator{}))
+	web.NewHandlerWithPolicy(sessions, p).RegisterRoutes(engine)
+
+	serve := func(method, target, body, groups string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", "bob")
+		req.Header.Set("X-Groups", groups)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// /etc 没有匹配的规则，存储列表中隐藏，secret 目录在列表中隐藏
+	rec := serve(http.MethodGet, "/api/finder/index?id=20&adapter=srv&path=/srv/app", "", "dev")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("index = %d %s", rec.Code, rec.Body.String())
+	}
+	var storages finder.Storages
+	if err = json.Unmarshal(rec.Body.Bytes(), &storages); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(storages.Storages, []string{"srv"}) {
+		t.Errorf("storages = %v, want [srv]", storages.Storages)
+	}
+	var names []string
+	for _, file := range storages.Files {
+		names = append(names, file.Basename)
+	}
+	if !slices.Equal(names, []string{".", "..", "config.yaml"}) {
+		t.Errorf("files = %v, want [. .. config.yaml]", names)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		body   string
+		groups string
+		want   int
+	}{
+		{name: "list denied", method: http.MethodGet, target: "/api/finder/index?id=20&adapter=etc&path=/etc", want: http.StatusForbidden},
+		{name: "download denied", method: http.MethodGet, target: "/api/finder/download?id=20&path=/srv/app/secret/key", want: http.StatusForbidden},
+		{name: "new file read only", method: http.MethodPost, target: "/api/finder/new_file?id=20&adapter=srv&path=/srv/app",
+			body: `{"name": "a.txt"}`, groups: "dev", want: http.StatusForbidden},
+		{name: "new file ops", method: http.MethodPost, target: "/api/finder/new_file?id=20&adapter=srv&path=/srv/app",
+			body: `{"name": "a.txt"}`, groups: "ops", want: http.StatusOK},
+		{name: "rename out of app", method: http.MethodPost, target: "/api/finder/rename?id=20&adapter=srv&path=/srv/app",
+			body: `{"item": "/srv/app/a.txt", "name": "../a.txt"}`, groups: "ops", want: http.StatusForbidden},
+		{name: "remove with secret", method: http.MethodPost, target: "/api/finder/remove?id=20&adapter=srv&path=/srv",
+			body: `{"items": [{"path": "/srv/app", "type": "dir"}]}`, groups: "ops", want: http.StatusForbidden},
+		{name: "archive with secret", method: http.MethodPost, target: "/api/finder/archive?id=20&adapter=srv&path=/srv/app",
+			body: `{"name": "app.zip", "items": [{"path": "/srv/app", "type": "dir"}]}`, groups: "ops", want: http.StatusForbidden},
+		{name: "archive name with extension", method: http.MethodPost, target: "/api/finder/archive?id=20&adapter=srv&path=/srv/app",
+			body: `{"name": "release", "format": "tar.gz", "items": [{"path": "/srv/app/config.yaml", "type": "file"}]}`, groups: "ops",
+			want: http.StatusForbidden},
+		{name: "archive ops", method: http.MethodPost, target: "/api/finder/archive?id=20&adapter=srv&path=/srv/app",
+			body: `{"name": "release", "items": [{"path": "/srv/app/config.yaml", "type": "file"}]}`, groups: "ops", want: http.StatusOK},
+		{name: "copy with secret", method: http.MethodPost, target: "/api/finder/copy?id=20&adapter=srv&path=/srv/app",
+			body: `{"item": "/srv/app/a.txt", "items": [{"path": "/srv/app", "type": "dir"}]}`, groups: "ops", want: http.StatusForbidden},
+		{name: "move with secret", method: http.MethodPost, target: "/api/finder/move?id=20&adapter=srv&path=/srv/app",
+			body: `{"item": "/srv/app/a.txt", "items": [{"path": "/srv/app", "type": "dir"}]}`, groups: "ops", want: http.StatusForbidden},
+		{name: "rename with secret", method: http.MethodPost, target: "/api/finder/rename?id=20&adapter=srv&path=/srv",
+			body: `{"item": "/srv/app", "name": "app2"}`, groups: "ops", want: http.StatusForbidden},
+		{name: "chmod recursive with secret", method: http.MethodPost, target: "/api/finder/chmod?id=20&adapter=srv&path=/srv/app",
+			body: `{"items": [{"path": "/srv/app", "type": "dir"}], "mode": "0750", "recursive": true}`, groups: "ops", want: http.StatusForbidden},
+		{name: "chmod ops", method: http.MethodPost, target: "/api/finder/chmod?id=20&adapter=srv&path=/srv/app",
+			body: `{"items": [{"path": "/srv/app", "type": "dir"}], "mode": "0750"}`, groups: "ops", want: http.StatusOK},
+		{name: "subfolders denied", method: http.MethodGet, target: "/api/finder/subfolders?id=20&adapter=etc&path=etc://", want: http.StatusForbidden},
+		{name: "subfolders secret denied", method: http.MethodGet, target: "/api/finder/subfolders?id=20&adapter=srv&path=/srv/app/secret",
+			want: http.StatusForbidden},
+		{name: "subfolders", method: http.MethodGet, target: "/api/finder/subfolders?id=20&adapter=srv&path=/srv", want: http.StatusOK},
+		{name: "search denied", method: http.MethodGet, target: "/api/finder/search?id=20&adapter=etc&path=/etc&filter=a", want: http.StatusForbidden},
+		{name: "search", method: http.MethodGet, target: "/api/finder/search?id=20&adapter=srv&path=/srv&filter=config", want: http.StatusOK},
+		{name: "remove ops", method: http.MethodPost, target: "/api/finder/remove?id=20&adapter=srv&path=/srv/app",
+			body: `{"items": [{"path": "/srv/app/a.txt", "type": "file"}]}`, groups: "ops", want: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := serve(tc.method, tc.target, tc.body, tc.groups); rec.Code != tc.want {
+				t.Errorf("status = %d %s, want %d", rec.Code, rec.Body.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestHandlerCapabilities(t *testing.T) {
+	ctx := context.Background()
+	fd := finder.NewMemoryFinder()
+	if err := fd.Put(ctx, "/srv/app/a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := session.NewManager(nil, sshx.Config{})
+	sessions.Register(20, session.KindMemory, fd)
+	sessions.Register(21, session.KindMemory, finder.NewReadOnlyFinder(fd))
+
+	p, err := policy.New([]policy.Rule{
+		{Users: []string{"*"}, Path: "/srv", Allow: []policy.Action{policy.Read, policy.Write}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	web.NewHandlerWithPolicy(sessions, p).RegisterRoutes(engine)
+
+	capabilities := func(target string) finder.Capabilities {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("capabilities = %d %s", rec.Code, rec.Body.String())
+		}
+
+		var c finder.Capabilities
+		if err := json.Unmarshal(rec.Body.Bytes(), &c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	if c := capabilities("/api/finder?q=capabilities&id=20"); c.ReadOnly || !c.Remove || c.Chown {
+		t.Errorf("memory = %+v, want all except chown", c)
+	}
+	if c := capabilities("/api/finder/capabilities?id=20&path=/srv/app"); !c.Upload || c.Remove || c.Move {
+		t.Errorf("memory /srv/app = %+v, want write without delete", c)
+	}
+	if c := capabilities("/api/finder/capabilities?id=21"); !c.ReadOnly || c.Upload || c.Save {
+		t.Errorf("read-only = %+v, want no modification", c)
+	}
+
+	// 只读会话修改文件返回 403
+	req := httptest.NewRequest(http.MethodPost, "/api/finder/save?id=21&path=/srv/app/a.txt", strings.NewReader(`{"content": "b"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("save = %d %s, want %d", rec.Code, rec.Body.String(), http.StatusForbidden)
+	}
+
+	for _, s := range sessions.List() {
+		if s.ReadOnly != (s.Id == 21) {
+			t.Errorf("session %d read_only = %v", s.Id, s.ReadOnly)
+		}
+	}
+}
+
+func TestHandlerDownloadRange(t *testing.T) {
+	fd := finder.NewMemoryFinder()
+	if err := fd.Put(context.Background(), "/data/a.txt", strings.NewReader("0123456789"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := session.NewManager(nil, sshx.Config{})
+	sessions.Register(20, session.KindMemory, fd)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	web.NewHandler(sessions).RegisterRoutes(engine)
+
+	serve := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	full := serve("/api/finder/download?id=20&path=/data/a.txt", nil)
+	etag, modified := full.Header().Get("ETag"), full.Header().Get("Last-Modified")
+	if full.Code != http.StatusOK || full.Body.String() != "0123456789" || etag == "" || modified == "" {
+		t.Fatalf("download = %d %q etag=%q last-modified=%q", full.Code, full.Body.String(), etag, modified)
+	}
+	if full.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", full.Header().Get("Accept-Ranges"))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		header map[string]string
+		code   int
+		body   string
+		// contentRange 为空时不校验
+		contentRange string
+	}{
+		{name: "range", header: map[string]string{"Range": "bytes=2-5"},
+			code: http.StatusPartialContent, body: "2345", contentRange: "bytes 2-5/10"},
+		{name: "suffix range", header: map[string]string{"Range": "bytes=-3"},
+			code: http.StatusPartialContent, body: "789", contentRange: "bytes 7-9/10"},
+		{name: "preview range", target: "/api/finder?q=preview&id=20&path=/data/a.txt", header: map[string]string{"Range": "bytes=8-"},
+			code: http.StatusPartialContent, body: "89", contentRange: "bytes 8-9/10"},
+		{name: "unsatisfiable range", header: map[string]string{"Range": "bytes=20-"},
+			code: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+		{name: "if none match", header: map[string]string{"If-None-Match": etag}, code: http.StatusNotModified},
+		{name: "if none match changed", header: map[string]string{"If-None-Match": `"changed"`}, code: http.StatusOK, body: "0123456789"},
+		{name: "if modified since", header: map[string]string{"If-Modified-Since": modified}, code: http.StatusNotModified},
+		{name: "if range", header: map[string]string{"Range": "bytes=0-1", "If-Range": etag},
+			code: http.StatusPartialContent, body: "01", contentRange: "bytes 0-1/10"},
+		// 文件已经变化时忽略 Range，重新下载整个文件
+		{name: "if range changed", header: map[string]string{"Range": "bytes=0-1", "If-Range": `"changed"`},
+			code: http.StatusOK, body: "0123456789"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			target := tc.target
+			if target == "" {
+				target = "/api/finder/download?id=20&path=/data/a.txt"
+			}
+
+			// 416 的响应体为 http.ServeContent 的错误信息，不校验
+			rec := serve(target, tc.header)
+			if rec.Code != tc.code || tc.code != http.StatusRequestedRangeNotSatisfiable && rec.Body.String() != tc.body {
+				t.Errorf("status = %d %q, want %d %q", rec.Code, rec.Body.String(), tc.code, tc.body)
+			}
+			if tc.contentRange != "" && rec.Header().Get("Content-Range") != tc.contentRange {
+				t.Errorf("Content-Range = %q, want %q", rec.Header().Get("Content-Range"), tc.contentRange)
+			}
+		})
+	}
+}
+
+// TestHandlerDispatchRoutes q 参数分发的每个操作与对应的独立路径返回相同的结果
+func TestHandlerDispatchRoutes(t *testing.T) {
+	newEngine := func(t *testing.T) *gin.Engine {
+		ctx := context.Background()
+		fd := finder.NewMemoryFinder()
+		for file, content := range map[string]string{"/data/a.txt": "alpha", "/data/dir/b.txt": "beta"} {
+			if err := fd.Put(ctx, file, strings.NewReader(content), int64(len(content))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := fd.Archive(ctx, []finder.Item{{Path: "/data/dir", Type: finder.DIR}}, "a.zip", "/data", finder.FormatZip); err != nil {
+			t.Fatal(err)
+		}
+
+		sessions := session.NewManager(nil, sshx.Config{})
+		sessions.Register(20, session.KindMemory, fd)
+
+		gin.SetMode(gin.TestMode)
+		engine := gin.New()
+		web.NewHandler(sessions).RegisterRoutes(engine)
+		return engine
+	}
+
+	// 修改时间精确到秒，两次请求之间可能变化
+	modified := regexp.MustCompile(`"last_modified":\d+`)
+	serve := func(engine *gin.Engine, method, target, contentType, body string) (int, string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec.Code, modified.ReplaceAllString(rec.Body.String(), `"last_modified":0`)
+	}
+
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	_ = mw.WriteField("name", "c.txt")
+	fw, err := mw.CreateFormFile("file", "c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write([]byte("gamma"))
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	items := `"items": [{"path": "/data/a.txt", "type": "file"}]`
+	for _, tc := range []struct {
+		q, legacy, method, query, body string
+		contentType                    string
+	}{
+		{q: "index", legacy: "index", method: http.MethodGet, query: "adapter=data&path=/data"},
+		{q: "subfolders", legacy: "subfolders", method: http.MethodGet, query: "adapter=data&path=/data"},
+		{q: "download", legacy: "download", method: http.MethodGet, query: "path=/data/a.txt"},
+		{q: "preview", legacy: "preview", method: http.MethodGet, query: "path=/data/a.txt"},
+		{q: "search", legacy: "search", method: http.MethodGet, query: "adapter=data&path=/data&filter=b"},
+		{q: "grep", legacy: "grep", method: http.MethodGet, query: "path=/data&pattern=alpha"},
+		{q: "capabilities", legacy: "capabilities", method: http.MethodGet},
+		{q: "upload", legacy: "upload", method: http.MethodPost, query: "adapter=data&path=/data",
+			body: upload.String(), contentType: mw.FormDataContentType()},
+		{q: "newfile", legacy: "new_file", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"name": "new.txt"}`},
+		{q: "newfolder", legacy: "new_folder", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"name": "new"}`},
+		{q: "rename", legacy: "rename", method: http.MethodPost, query: "adapter=data&path=/data",
+			body: `{"item": "/data/a.txt", "name": "c.txt"}`},
+		{q: "move", legacy: "move", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"item": "/data/dir", ` + items + `}`},
+		{q: "copy", legacy: "copy", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"item": "/data/dir", ` + items + `}`},
+		{q: "transfer", legacy: "transfer", method: http.MethodPost,
+			body: `{"from": 20, "to": 20, "target": "/data/dir", ` + items + `}`},
+		{q: "chmod", legacy: "chmod", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"mode": "600", ` + items + `}`},
+		// 内存实现不支持 chown，两个路径返回相同的错误
+		{q: "chown", legacy: "chown", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"owner": "root", ` + items + `}`},
+		{q: "delete", legacy: "remove", method: http.MethodPost, query: "adapter=data&path=/data", body: `{` + items + `}`},
+		{q: "archive", legacy: "archive", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"name": "b.zip", ` + items + `}`},
+		{q: "unarchive", legacy: "unarchive", method: http.MethodPost, query: "adapter=data&path=/data", body: `{"item": "/data/a.zip"}`},
+		{q: "save", legacy: "save", method: http.MethodPost, query: "path=/data/a.txt", body: `{"content": "changed"}`},
+	} {
+		t.Run(tc.q, func(t *testing.T) {
+			contentType := tc.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			query := "id=20&" + tc.query
+			code, body := serve(newEngine(t), tc.method, "/api/finder?q="+tc.q+"&"+query, contentType, tc.body)
+			legacyCode, legacyBody := serve(newEngine(t), tc.method, "/api/finder/"+tc.legacy+"?"+query, contentType, tc.body)
+			if code != legacyCode || body != legacyBody {
+				t.Errorf("q=%s = %d %s, /%s = %d %s", tc.q, code, body, tc.legacy, legacyCode, legacyBody)
+			}
+			if code == http.StatusBadRequest || code == http.StatusNotFound {
+				t.Errorf("q=%s was not dispatched: %d %s", tc.q, code, body)
+			}
+		})
+	}
+
+	// 只注册在 POST 上的操作不能通过 GET 调用
+	if code, _ := serve(newEngine(t), http.MethodGet, "/api/finder?q=delete&id=20", "", ""); code != http.StatusBadRequest {
+		t.Errorf("GET q=delete = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+// TestHandlerGrepRelativePath SSH 会话在远程主机上执行 find，以 - 开头的路径会被当作表达式
+func TestHandlerGrepRelativePath(t *testing.T) {
+	fd := finder.NewMemoryFinder()
+	if err := fd.Put(context.Background(), "/data/a.txt", strings.NewReader("keep"), 4); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := session.NewManager(nil, sshx.Config{})
+	sessions.Register(20, session.KindMemory, finder.NewReadOnlyFinder(fd))
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	web.NewHandler(sessions).RegisterRoutes(engine)
+
+	for target, want := range map[string]int{
+		"/api/finder?q=grep&id=20&path=-delete&pattern=keep": http.StatusInternalServerError,
+		"/api/finder?q=grep&id=20&path=data&pattern=keep":    http.StatusInternalServerError,
+		"/api/finder/grep?id=20&path=-delete&pattern=keep":   http.StatusInternalServerError,
+		"/api/finder?q=grep&id=20&path=/data&pattern=keep":   http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Errorf("%s = %d %s, want %d", target, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
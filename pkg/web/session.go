@@ -0,0 +1,112 @@
+package web
+
+import (
+	"errors"
+	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/Duke1616/vuefinder-go/pkg/policy"
+	"github.com/Duke1616/vuefinder-go/pkg/session"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type SessionHandler struct {
+	sessions *session.Manager
+	// policy 为 nil 时任何调用方都可以使用会话接口
+	policy *policy.Policy
+}
+
+func NewSessionHandler(sessions *session.Manager) *SessionHandler {
+	return &SessionHandler{
+		sessions: sessions,
+	}
+}
+
+// NewSessionHandlerWithPolicy 只有策略中的管理员可以使用会话接口
+func NewSessionHandlerWithPolicy(sessions *session.Manager, p *policy.Policy) *SessionHandler {
+	return &SessionHandler{
+		sessions: sessions,
+		policy:   p,
+	}
+}
+
+func (h *SessionHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/api/session")
+
+	g.GET("/list", ginx.Wrap(h.List))
+	g.GET("/detail", ginx.Wrap(h.Detail))
+	g.POST("/create", ginx.WrapBody(h.Create))
+	g.POST("/close", ginx.WrapBody(h.Close))
+}
+
+func (h *SessionHandler) Create(ctx *gin.Context, req CreateSessionReq) (ginx.Result, error) {
+	if err := h.authorize(ctx); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	s, err := h.sessions.Open(session.OpenReq{
+		Host:       req.Host,
+		User:       req.User,
+		Credential: req.Credential,
+		Root:       req.Root,
+		ReadOnly:   req.ReadOnly,
+	})
+	if err != nil {
+		// 主机密钥校验失败时返回指纹，便于运维人员核对
+		var hostKeyErr *sshx.HostKeyError
+		if errors.As(err, &hostKeyErr) {
+			return ginx.Result{Message: err.Error(), Data: hostKeyErr}, err
+		}
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return ginx.Result{Message: "OK", Data: s}, nil
+}
+
+// List 会话中包含主机、用户以及凭证名称，同样只允许管理员查看
+func (h *SessionHandler) List(ctx *gin.Context) (ginx.Result, error) {
+	if err := h.authorize(ctx); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return ginx.Result{Message: "OK", Data: h.sessions.List()}, nil
+}
+
+func (h *SessionHandler) Detail(ctx *gin.Context) (ginx.Result, error) {
+	if err := h.authorize(ctx); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	id, err := strconv.ParseInt(ctx.Query("id"), 10, 64)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	s, err := h.sessions.Get(id)
+	if err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return ginx.Result{Message: "OK", Data: s}, nil
+}
+
+func (h *SessionHandler) Close(ctx *gin.Context, req CloseSessionReq) (ginx.Result, error) {
+	if err := h.authorize(ctx); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	if err := h.sessions.Close(req.Id); err != nil {
+		return ginx.Result{Message: err.Error()}, err
+	}
+
+	return ginx.Result{Message: "OK"}, nil
+}
+
+// authorize 没有配置策略时不做检查
+func (h *SessionHandler) authorize(ctx *gin.Context) error {
+	if h.policy == nil {
+		return nil
+	}
+
+	return h.policy.CheckAdmin(subject(ctx))
+}
@@ -0,0 +1,75 @@
+package web_test
+
+import (
+	"github.com/Duke1616/vuefinder-go/pkg/finder"
+	"github.com/Duke1616/vuefinder-go/pkg/ginx"
+	"github.com/Duke1616/vuefinder-go/pkg/policy"
+	"github.com/Duke1616/vuefinder-go/pkg/session"
+	"github.com/Duke1616/vuefinder-go/pkg/sshx"
+	"github.com/Duke1616/vuefinder-go/pkg/web"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSessionHandlerPolicy(t *testing.T) {
+	sessions := session.NewManager(map[string]session.Credential{
+		"ops": {Auth: sshx.Auth{Password: "123456"}, Hosts: []string{"10.0.0.*:22"}},
+	}, sshx.Config{})
+	sessions.Register(20, session.KindMemory, finder.NewMemoryFinder())
+
+	p, err := policy.NewWithAdmins(policy.Admins{Groups: []string{"admin"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(ginx.Authenticate(headerAuthenticator{}))
+	web.NewSessionHandlerWithPolicy(sessions, p).RegisterRoutes(engine)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		body   string
+		groups string
+		want   int
+	}{
+		{name: "list not admin", method: http.MethodGet, target: "/api/session/list", groups: "ops", want: http.StatusForbidden},
+		{name: "list admin", method: http.MethodGet, target: "/api/session/list", groups: "admin", want: http.StatusOK},
+		{name: "detail not admin", method: http.MethodGet, target: "/api/session/detail?id=20", groups: "ops", want: http.StatusForbidden},
+		{name: "detail admin", method: http.MethodGet, target: "/api/session/detail?id=20", groups: "admin", want: http.StatusOK},
+		{name: "create not admin", target: "/api/session/create",
+			body: `{"host": "10.0.0.8:22", "user": "root", "credential": "ops"}`, groups: "ops", want: http.StatusForbidden},
+		{name: "create host not allowed", target: "/api/session/create",
+			body: `{"host": "192.168.1.8:22", "user": "root", "credential": "ops"}`, groups: "admin", want: http.StatusForbidden},
+		// 通过授权检查后才会连接，测试中没有配置主机密钥校验，连接失败
+		{name: "create admin", target: "/api/session/create",
+			body: `{"host": "10.0.0.8:22", "user": "root", "credential": "ops"}`, groups: "admin", want: http.StatusInternalServerError},
+		{name: "close not admin", target: "/api/session/close", body: `{"id": 20}`, groups: "ops", want: http.StatusForbidden},
+		{name: "close admin", target: "/api/session/close", body: `{"id": 20}`, groups: "admin", want: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-User", "bob")
+			req.Header.Set("X-Groups", tc.groups)
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d %s, want %d", rec.Code, rec.Body.String(), tc.want)
+			}
+		})
+	}
+
+	if list := sessions.List(); len(list) != 0 {
+		t.Errorf("sessions = %v, want the closed session removed", list)
+	}
+}
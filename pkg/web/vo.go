@@ -18,6 +18,14 @@ type RenameReq struct {
 type ArchiveReq struct {
 	Name  string `json:"name"`
 	Items []Item `json:"items"`
+	// Format 压缩包格式，可选 zip、tar、tar.gz、tar.zst，为空时根据名称后缀识别，默认 zip
+	Format finder.ArchiveFormat `json:"format"`
+}
+
+type UnarchiveReq struct {
+	Item string `json:"item"`
+	// Conflict 已存在文件的处理方式，可选 error、skip、overwrite，默认 error
+	Conflict finder.ConflictPolicy `json:"conflict"`
 }
 
 type MoveReq struct {
@@ -25,6 +33,42 @@ type MoveReq struct {
 	Items []Item `json:"items"`
 }
 
+type CopyReq struct {
+	// Item 复制到的目标目录
+	Item  string `json:"item"`
+	Items []Item `json:"items"`
+	// Conflict 已存在文件的处理方式，可选 error、skip、overwrite、rename，默认 error
+	Conflict finder.ConflictPolicy `json:"conflict"`
+}
+
+// ChmodReq Mode 支持八进制例如 0755 以及符号模式例如 u+x,go-w
+type ChmodReq struct {
+	Items     []Item `json:"items"`
+	Mode      string `json:"mode"`
+	Recursive bool   `json:"recursive"`
+}
+
+// ChownReq Owner Group 支持名称以及数字 id，为空时保持不变
+type ChownReq struct {
+	Items     []Item `json:"items"`
+	Owner     string `json:"owner"`
+	Group     string `json:"group"`
+	Recursive bool   `json:"recursive"`
+}
+
+// TransferReq 在两个会话之间传输文件，From 与 To 为 finder id
+type TransferReq struct {
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+	Items []Item `json:"items"`
+	// Target 目标会话中的目录
+	Target string `json:"target"`
+	// Move 传输完成后删除源文件
+	Move bool `json:"move"`
+	// Conflict 已存在文件的处理方式，可选 error、skip、overwrite、rename，默认 error
+	Conflict finder.ConflictPolicy `json:"conflict"`
+}
+
 type SaveReq struct {
 	Content string `json:"content"`
 }
@@ -33,11 +77,77 @@ type RemoveReq struct {
 	Items []Item `json:"items"`
 }
 
+// SearchReq 搜索条件通过查询参数传递，filter 与 VueFinder 保持一致
+type SearchReq struct {
+	Filter string `form:"filter"`
+	// Match 匹配方式，可选 contains、glob、regex，默认 contains
+	Match      finder.MatchMode `form:"match"`
+	IgnoreCase bool             `form:"ignore_case"`
+	// MaxDepth 最大搜索深度，1 表示只搜索当前目录
+	MaxDepth int `form:"max_depth"`
+	// Type 可选 file、dir
+	Type    finder.FileType `form:"type"`
+	MinSize int64           `form:"min_size"`
+	MaxSize int64           `form:"max_size"`
+	// ModifiedAfter ModifiedBefore Unix 时间戳
+	ModifiedAfter  int64 `form:"modified_after"`
+	ModifiedBefore int64 `form:"modified_before"`
+	Limit          int   `form:"limit"`
+}
+
+// GrepReq 内容搜索条件通过查询参数传递
+type GrepReq struct {
+	Pattern string `form:"pattern" binding:"required"`
+	// Regex 为 true 时按照正则表达式匹配，否则按照固定字符串匹配
+	Regex      bool `form:"regex"`
+	IgnoreCase bool `form:"ignore_case"`
+	// Include 只搜索名称匹配的文件，例如 *.log
+	Include     string `form:"include"`
+	MaxFileSize int64  `form:"max_file_size"`
+	Limit       int    `form:"limit"`
+}
+
 type Item struct {
 	Path string          `json:"path"`
 	Type finder.FileType `json:"type"`
 }
 
 type RetrieveFolder struct {
-	Folders []finder.FileInfo `json:"folders"`
+	Folders    []finder.FileInfo  `json:"folders"`
+	Pagination *finder.Pagination `json:"pagination,omitempty"`
+}
+
+// ListReq 排序以及分页条件，都不传时与 VueFinder 的默认请求一致，返回全部条目
+type ListReq struct {
+	// Sort 可选 name、size、mtime、type
+	Sort  finder.SortField `form:"sort"`
+	Order string           `form:"order" binding:"omitempty,oneof=asc desc"`
+	// Offset 与 Cursor 二选一，Cursor 为上一页返回的 next_cursor
+	Offset   int    `form:"offset" binding:"min=0"`
+	PageSize int    `form:"page_size" binding:"min=0"`
+	Cursor   string `form:"cursor"`
+}
+
+func (r ListReq) options() finder.ListOptions {
+	return finder.ListOptions{
+		Sort:   r.Sort,
+		Desc:   r.Order == "desc",
+		Offset: r.Offset,
+		Limit:  r.PageSize,
+		Cursor: r.Cursor,
+	}
+}
+
+type CreateSessionReq struct {
+	Host       string `json:"host"`
+	User       string `json:"user"`
+	Credential string `json:"credential"`
+	// Root 在凭证配置的根目录之内进一步限制，路径相对于凭证的根目录
+	Root string `json:"root"`
+	// ReadOnly 只允许浏览、搜索、下载以及预览，凭证配置了只读时无法解除
+	ReadOnly bool `json:"read_only"`
+}
+
+type CloseSessionReq struct {
+	Id int64 `json:"id"`
 }